@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authSettings are the API keys clients must send. It's replaced on
+// startup by the configured ones.
+var authSettings authConfig
+
+// publicRoutes can be used without an API key, so the documentation can be
+// read before having one.
+var publicRoutes = map[string]bool{"/openapi.json": true, "/docs": true}
+
+// authMiddleware responds with a 401 problem to the requests that don't
+// have one of the API keys of settings in their Authorization header, as
+// in "Authorization: Bearer <key>". Every request is let through when
+// there's no key.
+func authMiddleware(settings authConfig) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if len(settings.APIKeys) == 0 || publicRoutes[context.FullPath()] ||
+			isApiKeyAuthorized(settings, context.GetHeader("Authorization")) {
+			context.Next()
+			return
+		}
+
+		unauthorizedProblem := newStatusProblem(http.StatusUnauthorized)
+		unauthorizedProblem.Detail = "A valid API key must be sent in the Authorization header, as in: Bearer <key>"
+
+		context.Header("WWW-Authenticate", `Bearer realm="customers-api"`)
+		abortWithProblem(unauthorizedProblem, context)
+	}
+}
+
+// isApiKeyAuthorized reports whether authorization is a bearer token with
+// one of the API keys of settings. Keys are compared in constant time.
+func isApiKeyAuthorized(settings authConfig, authorization string) bool {
+	scheme, token, isFound := strings.Cut(authorization, " ")
+
+	if !isFound || !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+
+	isAuthorized := false
+
+	for _, key := range settings.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(key)) == 1 {
+			isAuthorized = true
+		}
+	}
+
+	return isAuthorized
+}
+
+// grpcAuthUnaryInterceptor and grpcAuthStreamInterceptor require the same
+// API keys as authMiddleware, sent in the authorization metadata.
+func grpcAuthUnaryInterceptor(settings authConfig) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := authorizeGrpcCall(ctx, settings); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, request)
+	}
+}
+
+func grpcAuthStreamInterceptor(settings authConfig) grpc.StreamServerInterceptor {
+	return func(server interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := authorizeGrpcCall(stream.Context(), settings); err != nil {
+			return err
+		}
+
+		return handler(server, stream)
+	}
+}
+
+func authorizeGrpcCall(ctx context.Context, settings authConfig) error {
+	if len(settings.APIKeys) == 0 {
+		return nil
+	}
+
+	for _, authorization := range metadata.ValueFromIncomingContext(ctx, "authorization") {
+		if isApiKeyAuthorized(settings, authorization) {
+			return nil
+		}
+	}
+
+	return status.Error(codes.Unauthenticated, "a valid API key must be sent in the authorization metadata, as in: Bearer <key>")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"codesherpas/customer_api/customerpb"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	authSettings.APIKeys = []string{"first-key", "second-key"}
+
+	defer func() {
+		authSettings = authConfig{}
+		clearCustomers(nil)
+	}()
+
+	router := setupRouter()
+
+	writer := sendRequestForTesting(router, "GET", "/v1/customers", nil)
+	assert.Equal(t, 401, writer.Code)
+	assert.Equal(t, `Bearer realm="customers-api"`, writer.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, "Unauthorized", getProblemFromResponse(t, writer)["title"])
+
+	for authorization, wantCode := range map[string]int{
+		"Bearer second-key": 200,
+		"bearer first-key":  200,
+		"Bearer third-key":  401,
+		"Basic first-key":   401,
+		"first-key":         401,
+	} {
+		request := httptest.NewRequest("GET", "/v1/customers", nil)
+		request.Header.Set("Authorization", authorization)
+		writer = httptest.NewRecorder()
+		router.ServeHTTP(writer, request)
+
+		assert.Equal(t, wantCode, writer.Code, authorization)
+	}
+
+	// The documentation is public.
+	writer = sendRequestForTesting(router, "GET", "/openapi.json", nil)
+	assert.Equal(t, 200, writer.Code)
+}
+
+func TestGrpcAuth(t *testing.T) {
+	repository = newInMemoryCustomerRepository()
+	authSettings.APIKeys = []string{"first-key"}
+
+	defer func() {
+		authSettings = authConfig{}
+		clearCustomers(nil)
+	}()
+
+	client := getGrpcClientForTesting(t)
+
+	_, err := client.GetCustomer(context.Background(), &customerpb.GetCustomerRequest{Id: "1"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer first-key")
+	_, err = client.GetCustomer(ctx, &customerpb.GetCustomerRequest{Id: "1"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
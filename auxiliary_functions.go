@@ -2,21 +2,18 @@ package main
 
 import (
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 func validateId(id string, context *gin.Context) bool {
 	if id == "" {
-		context.IndentedJSON(http.StatusBadRequest, gin.H{"error": "ID must not be empty"})
+		abortWithProblem(newProblem(http.StatusBadRequest, invalidIdProblemType, "ID must not be empty", ""), context)
 		return false
 	}
 
-	int1, err := strconv.ParseInt(id, 6, 12)
-
-	if err != nil || int1 < 0 {
-		context.IndentedJSON(http.StatusBadRequest, gin.H{"error": "ID is not valid"})
+	if err := customerIdPolicy.Validate(id); err != nil {
+		abortWithProblem(newProblem(http.StatusBadRequest, invalidIdProblemType, "ID is not valid", ""), context)
 		return false
 	} else {
 		return true
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ugorji/go/codec"
+)
+
+const (
+	// maxBulkItems is the highest number of customers (or IDs) of a bulk
+	// request.
+	maxBulkItems = 1000
+
+	bulkAtomicParameter = "atomic"
+	bulkDryRunParameter = "dry_run"
+)
+
+var (
+	// errBulkItemFailed makes an atomic bulk request roll back every
+	// change.
+	errBulkItemFailed = errors.New("an item of the bulk request failed")
+	// errBulkDryRun rolls back the changes of a dry run.
+	errBulkDryRun = errors.New("dry run")
+)
+
+// bulkItemResult is what happened to one customer of a bulk request. Index
+// is its position in the request, Row its line if it was imported from a
+// CSV file, and Status the code it would have gotten on its own, along with
+// the stored customer or the problem found.
+type bulkItemResult struct {
+	Index    int       `json:"index" xml:"index" yaml:"index"`
+	Row      int       `json:"row,omitempty" xml:"row,omitempty" yaml:"row,omitempty"`
+	ID       string    `json:"id,omitempty" xml:"id,omitempty" yaml:"id,omitempty"`
+	Status   int       `json:"status" xml:"status" yaml:"status"`
+	Customer *customer `json:"customer,omitempty" xml:"customer,omitempty" yaml:"customer,omitempty"`
+	// Version is the version of Customer, which can be sent back in a
+	// bulk update or delete, as the ETag is sent in If-Match.
+	Version uint64   `json:"version,omitempty" xml:"version,omitempty" yaml:"version,omitempty"`
+	Problem *problem `json:"problem,omitempty" xml:"problem,omitempty" yaml:"problem,omitempty"`
+	// err is what caused Problem.
+	err error
+}
+
+// bulkResponse is the body of the bulk endpoints.
+type bulkResponse struct {
+	XMLName   xml.Name         `json:"-" xml:"bulk" yaml:"-"`
+	Results   []bulkItemResult `json:"results" xml:"results>result" yaml:"results"`
+	Succeeded int              `json:"succeeded" xml:"succeeded" yaml:"succeeded"`
+	Failed    int              `json:"failed" xml:"failed" yaml:"failed"`
+}
+
+// bulkOperation applies the change of one item of a bulk request to
+// targetRepository.
+type bulkOperation func(ctx context.Context, targetRepository CustomerRepository, index int) bulkItemResult
+
+// postCustomers adds every customer of the array received in the request
+// body, as postCustomer does with each one.
+func postCustomers(context *gin.Context) {
+	var newCustomers []customer
+
+	if bindBulkBody(&newCustomers, context) {
+		runBulkOperation(len(newCustomers), maxBulkItems, http.StatusCreated, newBulkCreateOperation(newCustomers), context)
+	}
+}
+
+// updateCustomers replaces the information of every customer of the array
+// received in the request body, finding them by their ID. Each one can
+// have the version it must still be at, which is required when If-Match
+// is.
+func updateCustomers(context *gin.Context) {
+	var items []bulkUpdateItem
+
+	if bindBulkBody(&items, context) {
+		runBulkOperation(len(items), maxBulkItems, http.StatusOK, newBulkUpdateOperation(items), context)
+	}
+}
+
+// deleteCustomers removes every customer of the array received in the
+// request body, which has their IDs or, to delete them only if they're
+// still at a version, objects with their ID and version.
+func deleteCustomers(context *gin.Context) {
+	var items []bulkDeleteItem
+
+	if bindBulkBody(&items, context) {
+		runBulkOperation(len(items), maxBulkItems, http.StatusOK, newBulkDeleteOperation(items), context)
+	}
+}
+
+func newBulkCreateOperation(newCustomers []customer) bulkOperation {
+	return func(ctx context.Context, targetRepository CustomerRepository, index int) bulkItemResult {
+		createdCustomer, err := createCustomer(ctx, targetRepository, newCustomers[index])
+
+		if err != nil {
+			return newFailedBulkItemResult(index, newCustomers[index].ID, err)
+		}
+
+		return bulkItemResult{Index: index, ID: createdCustomer.ID, Status: http.StatusCreated, Customer: &createdCustomer,
+			Version: createdCustomer.Version}
+	}
+}
+
+func newBulkUpdateOperation(items []bulkUpdateItem) bulkOperation {
+	return func(ctx context.Context, targetRepository CustomerRepository, index int) bulkItemResult {
+		newCustomer := items[index].customer
+
+		if fieldErrors := validateCustomer(newCustomer); len(fieldErrors) > 0 {
+			return newFailedBulkItemResult(index, newCustomer.ID, newValidationProblem(fieldErrors))
+		}
+
+		if requireIfMatch && items[index].Version == 0 {
+			return newFailedBulkItemResult(index, newCustomer.ID, newPreconditionRequiredProblem(bulkVersionRequiredDetail))
+		}
+
+		updatedCustomer, err := targetRepository.Update(ctx, newCustomer.ID, newCustomer, expectVersion(items[index].Version)...)
+
+		if err != nil {
+			return newFailedBulkItemResult(index, newCustomer.ID, err)
+		}
+
+		return bulkItemResult{Index: index, ID: updatedCustomer.ID, Status: http.StatusOK, Customer: &updatedCustomer,
+			Version: updatedCustomer.Version}
+	}
+}
+
+func newBulkDeleteOperation(items []bulkDeleteItem) bulkOperation {
+	return func(ctx context.Context, targetRepository CustomerRepository, index int) bulkItemResult {
+		id := items[index].ID
+
+		if fieldError := validateCustomerId(id); fieldError != nil {
+			return newFailedBulkItemResult(index, id,
+				newProblem(http.StatusBadRequest, invalidIdProblemType, fieldError.Message, ""))
+		}
+
+		if requireIfMatch && items[index].Version == 0 {
+			return newFailedBulkItemResult(index, id, newPreconditionRequiredProblem(bulkVersionRequiredDetail))
+		}
+
+		if err := targetRepository.Delete(ctx, id, expectVersion(items[index].Version)...); err != nil {
+			return newFailedBulkItemResult(index, id, err)
+		}
+
+		return bulkItemResult{Index: index, ID: id, Status: http.StatusOK}
+	}
+}
+
+// bulkVersionRequiredDetail explains the items without a version when
+// If-Match is required.
+const bulkVersionRequiredDetail = "The version of the customer is required"
+
+// bulkUpdateItem is a customer of a bulk update, along with the version it
+// must still be at to be replaced, or 0 for any version.
+type bulkUpdateItem struct {
+	customer `yaml:",inline"`
+	Version  uint64 `json:"version,omitempty" xml:"version,omitempty" yaml:"version,omitempty"`
+}
+
+// bulkDeleteItem is a customer of a bulk delete: its ID and the version it
+// must still be at to be removed, or 0 for any version. It's sent either
+// as the ID alone or as an object with both.
+type bulkDeleteItem struct {
+	ID      string `xml:"id"`
+	Version uint64 `xml:"version"`
+}
+
+func (item *bulkDeleteItem) UnmarshalJSON(data []byte) error {
+	var value interface{}
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	return item.fromValue(value)
+}
+
+func (item *bulkDeleteItem) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value interface{}
+
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+
+	return item.fromValue(value)
+}
+
+// UnmarshalXML reads <id>1</id>, whatever the name of the element, or an
+// element with <id> and <version> children.
+func (item *bulkDeleteItem) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	var element struct {
+		Text    string  `xml:",chardata"`
+		ID      *string `xml:"id"`
+		Version uint64  `xml:"version"`
+	}
+
+	if err := decoder.DecodeElement(&element, &start); err != nil {
+		return err
+	}
+
+	if element.ID == nil {
+		*item = bulkDeleteItem{ID: strings.TrimSpace(element.Text)}
+	} else {
+		*item = bulkDeleteItem{ID: *element.ID, Version: element.Version}
+	}
+
+	return nil
+}
+
+// CodecEncodeSelf and CodecDecodeSelf read and write the item in
+// MessagePack.
+func (item *bulkDeleteItem) CodecEncodeSelf(encoder *codec.Encoder) {
+	encoder.MustEncode(map[string]interface{}{"id": item.ID, "version": item.Version})
+}
+
+func (item *bulkDeleteItem) CodecDecodeSelf(decoder *codec.Decoder) {
+	var value interface{}
+	decoder.MustDecode(&value)
+
+	if err := item.fromValue(value); err != nil {
+		panic(err)
+	}
+}
+
+// fromValue reads the item from what a decoder returns for it when it
+// isn't given a type: a string, or a map with its ID and version.
+func (item *bulkDeleteItem) fromValue(value interface{}) error {
+	if id, isText := getBulkText(value); isText {
+		*item = bulkDeleteItem{ID: id}
+		return nil
+	}
+
+	fields := map[string]interface{}{}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		fields = value
+	case map[interface{}]interface{}:
+		for key, fieldValue := range value {
+			name, _ := getBulkText(key)
+			fields[name] = fieldValue
+		}
+	default:
+		return fmt.Errorf("an item must be an ID or an object with its id and version, not %T", value)
+	}
+
+	*item = bulkDeleteItem{}
+
+	for name, fieldValue := range fields {
+		var isValid bool
+
+		switch name {
+		case "id":
+			item.ID, isValid = getBulkText(fieldValue)
+		case "version":
+			item.Version, isValid = getBulkVersion(fieldValue)
+		default:
+			return fmt.Errorf("unknown field %q", name)
+		}
+
+		if !isValid {
+			return fmt.Errorf("%s has the wrong type", name)
+		}
+	}
+
+	return nil
+}
+
+func getBulkText(value interface{}) (string, bool) {
+	switch value := value.(type) {
+	case string:
+		return value, true
+	case []byte:
+		return string(value), true
+	default:
+		return "", false
+	}
+}
+
+func getBulkVersion(value interface{}) (uint64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return uint64(value), value >= 0 && value == math.Trunc(value)
+	case int:
+		return uint64(value), value >= 0
+	case int64:
+		return uint64(value), value >= 0
+	case uint64:
+		return value, true
+	default:
+		return 0, false
+	}
+}
+
+// bindBulkBody binds the array of the request body to items. Since an XML
+// document has a single root, in XML the items are the children of the
+// root, whatever their names.
+func bindBulkBody[T any](items *[]T, context *gin.Context) bool {
+	if format, _ := getRequestFormat(context); format.MediaType != xmlContentType {
+		return bindRequestBody(items, context)
+	}
+
+	var root struct {
+		Items []T `xml:",any"`
+	}
+
+	if !bindRequestBody(&root, context) {
+		return false
+	}
+
+	*items = root.Items
+
+	return true
+}
+
+// runBulkOperation applies operation to each of the count items of a bulk
+// request, which can't have more than maxItems, and responds with the
+// result of every item. When the atomic query parameter is true, the
+// changes are only kept if every item succeeds; otherwise each item
+// succeeds or fails on its own. When dry_run is true, the results are
+// returned but nothing is kept.
+func runBulkOperation(count int, maxItems int, successStatus int, operation bulkOperation, context *gin.Context) {
+	if count < 1 || count > maxItems {
+		abortWithProblem(newInvalidBodyProblem(
+			fmt.Errorf("the request must have between 1 and %d items", maxItems)), context)
+		return
+	}
+
+	fieldErrors := []fieldError{}
+	isAtomic, err := strconv.ParseBool(context.DefaultQuery(bulkAtomicParameter, "false"))
+
+	if err != nil {
+		fieldErrors = append(fieldErrors, fieldError{bulkAtomicParameter, invalidFieldErrorCode, "Atomic must be true or false"})
+	}
+
+	isDryRun, err := strconv.ParseBool(context.DefaultQuery(bulkDryRunParameter, "false"))
+
+	if err != nil {
+		fieldErrors = append(fieldErrors, fieldError{bulkDryRunParameter, invalidFieldErrorCode, "Dry run must be true or false"})
+	}
+
+	if len(fieldErrors) > 0 {
+		abortWithProblem(newInvalidQueryProblem(fieldErrors), context)
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	if !isAtomic && !isDryRun {
+		response := applyBulkOperation(ctx, repository, count, operation)
+		logBulkErrors(response, context)
+		respond(http.StatusMultiStatus, response, context)
+		return
+	}
+
+	transactor, isTransactor := repository.(customerTransactor)
+
+	if !isTransactor {
+		notImplementedProblem := newStatusProblem(http.StatusNotImplemented)
+		notImplementedProblem.Detail = "Bulk requests can't be atomic nor dry runs"
+		abortWithProblem(notImplementedProblem, context)
+		return
+	}
+
+	var response bulkResponse
+
+	// A dry run is a transaction that's always rolled back, so each item
+	// sees the changes of the previous ones.
+	err = transactor.WithinTransaction(ctx, func(transaction CustomerRepository) error {
+		response = applyBulkOperation(ctx, transaction, count, operation)
+
+		if isAtomic && response.Failed > 0 {
+			return errBulkItemFailed
+		} else if isDryRun {
+			return errBulkDryRun
+		}
+
+		return nil
+	})
+
+	logBulkErrors(response, context)
+
+	switch {
+	case errors.Is(err, errBulkItemFailed):
+		abortWithProblem(newBulkRolledBackProblem(response), context)
+	case err != nil && !errors.Is(err, errBulkDryRun):
+		respondWithRepositoryError(err, context)
+	case isAtomic:
+		respond(successStatus, response, context)
+	default:
+		respond(http.StatusMultiStatus, response, context)
+	}
+}
+
+func applyBulkOperation(ctx context.Context, targetRepository CustomerRepository, count int, operation bulkOperation) bulkResponse {
+	response := bulkResponse{Results: make([]bulkItemResult, 0, count)}
+
+	for index := 0; index < count; index++ {
+		result := operation(ctx, targetRepository, index)
+
+		if result.Problem == nil {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+
+		response.Results = append(response.Results, result)
+	}
+
+	return response
+}
+
+// newFailedBulkItemResult returns the result of an item that failed with
+// err, which is a problem or an error returned by the repository.
+func newFailedBulkItemResult(index int, id string, err error) bulkItemResult {
+	itemProblem := newRepositoryProblem(err)
+
+	return bulkItemResult{Index: index, ID: id, Status: itemProblem.Status, Problem: itemProblem, err: err}
+}
+
+// logBulkErrors keeps the cause of the unexpected errors of the items for
+// the logs.
+func logBulkErrors(response bulkResponse, context *gin.Context) {
+	for _, result := range response.Results {
+		if result.Status == http.StatusInternalServerError {
+			context.Error(result.err)
+		}
+	}
+}
+
+// newBulkRolledBackProblem reports an atomic bulk request that changed
+// nothing because some of its items failed. The items that didn't fail are
+// marked with 424 Failed Dependency.
+func newBulkRolledBackProblem(response bulkResponse) *problem {
+	for i, result := range response.Results {
+		if result.Problem == nil {
+			dependencyProblem := newStatusProblem(http.StatusFailedDependency)
+			dependencyProblem.Detail = "Not applied because other items failed"
+
+			response.Results[i] = bulkItemResult{Index: result.Index, ID: result.ID,
+				Status: http.StatusFailedDependency, Problem: dependencyProblem}
+		}
+	}
+
+	rolledBackProblem := newProblem(http.StatusUnprocessableEntity, bulkRolledBackProblemType, "No customer was changed",
+		fmt.Sprintf("%d of %d items failed", response.Failed, len(response.Results)))
+	rolledBackProblem.Results = response.Results
+
+	return rolledBackProblem
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func getBulkResponseForTesting(t *testing.T, writer *httptest.ResponseRecorder) bulkResponse {
+	var got bulkResponse
+
+	if err := json.Unmarshal(writer.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	return got
+}
+
+func getBulkStatuses(results []bulkItemResult) []int {
+	statuses := []int{}
+
+	for _, result := range results {
+		statuses = append(statuses, result.Status)
+	}
+
+	return statuses
+}
+
+func TestRepositoryWithinTransaction(t *testing.T) {
+	for name, repository := range getRepositoriesForTesting(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			transactor := repository.(customerTransactor)
+			errRollback := errors.New("rollback")
+
+			err := transactor.WithinTransaction(ctx, func(transaction CustomerRepository) error {
+				_, err := transaction.Create(ctx, getMockedCustomer())
+				assert.NoError(t, err)
+
+				// The transaction sees its own changes.
+				_, err = transaction.Get(ctx, "1")
+				assert.NoError(t, err)
+
+				return errRollback
+			})
+
+			assert.ErrorIs(t, err, errRollback)
+
+			_, err = repository.Get(ctx, "1")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			err = transactor.WithinTransaction(ctx, func(transaction CustomerRepository) error {
+				_, err := transaction.Create(ctx, getMockedCustomer())
+				return err
+			})
+
+			assert.NoError(t, err)
+
+			got, err := repository.Get(ctx, "1")
+			assert.NoError(t, err)
+			assert.Equal(t, withVersion(getMockedCustomer(), 1), got)
+		})
+	}
+}
+
+func TestPostCustomersInBulk(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	invalidCustomer := getMockedCustomer()
+	invalidCustomer.ID = "2"
+	invalidCustomer.Email = "not an email"
+
+	writer := sendRequestForTesting(router, "POST", "/customers/bulk", []customer{getMockedCustomer(), invalidCustomer, getMockedCustomer()})
+	assert.Equal(t, 207, writer.Code)
+
+	got := getBulkResponseForTesting(t, writer)
+
+	assert.Equal(t, []int{201, 400, 409}, getBulkStatuses(got.Results))
+	assert.Equal(t, 1, got.Succeeded)
+	assert.Equal(t, 2, got.Failed)
+	assert.Equal(t, getMockedCustomer(), *got.Results[0].Customer)
+	assert.Equal(t, []fieldError{{"email", invalidFieldErrorCode, "Email is not valid"}}, got.Results[1].Problem.Errors)
+	assert.Equal(t, customerConflictProblemType, got.Results[2].Problem.Type)
+
+	customers, _ := listAllCustomers(context.Background(), repository)
+	assert.Len(t, customers, 1)
+}
+
+func TestUpdateAndDeleteCustomersInBulk(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	sendRequestForTesting(router, "POST", "/customers/bulk", getMockedCustomers())
+
+	updatedCustomer := getMockedUpdatedCustomerInformation()
+	unknownCustomer := getMockedCustomer()
+	unknownCustomer.ID = "unknown"
+
+	writer := sendRequestForTesting(router, "PUT", "/customers/bulk", []customer{updatedCustomer, unknownCustomer})
+	assert.Equal(t, 207, writer.Code)
+	assert.Equal(t, []int{200, 404}, getBulkStatuses(getBulkResponseForTesting(t, writer).Results))
+
+	stored, err := repository.Get(context.Background(), "1")
+	assert.NoError(t, err)
+	assert.Equal(t, withVersion(updatedCustomer, 2), stored)
+
+	writer = sendRequestForTesting(router, "DELETE", "/customers/bulk", []string{"1", "unknown", "", "2"})
+	assert.Equal(t, 207, writer.Code)
+
+	got := getBulkResponseForTesting(t, writer)
+
+	assert.Equal(t, []int{200, 404, 400, 200}, getBulkStatuses(got.Results))
+	assert.Equal(t, invalidIdProblemType, got.Results[2].Problem.Type)
+
+	customers, _ := listAllCustomers(context.Background(), repository)
+	assert.Len(t, customers, len(getMockedCustomers())-2)
+}
+
+func TestChangeCustomersInBulkWithVersions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	writer := sendRequestForTesting(router, "POST", "/customers/bulk", getMockedCustomers())
+	assert.Equal(t, uint64(1), getBulkResponseForTesting(t, writer).Results[0].Version)
+
+	updatedCustomer := getMockedUpdatedCustomerInformation()
+
+	writer = sendRequestForTesting(router, "PUT", "/customers/bulk", []bulkUpdateItem{{updatedCustomer, 2}, {updatedCustomer, 1}})
+	assert.Equal(t, 207, writer.Code)
+
+	got := getBulkResponseForTesting(t, writer)
+	assert.Equal(t, []int{412, 200}, getBulkStatuses(got.Results))
+	assert.Equal(t, versionMismatchProblemType, got.Results[0].Problem.Type)
+	assert.Equal(t, uint64(2), got.Results[1].Version)
+
+	// IDs and objects with a version can be mixed.
+	writer = sendRequestForTesting(router, "DELETE", "/customers/bulk", []interface{}{
+		map[string]interface{}{"id": "1", "version": 1}, "2",
+	})
+	assert.Equal(t, 207, writer.Code)
+	assert.Equal(t, []int{412, 200}, getBulkStatuses(getBulkResponseForTesting(t, writer).Results))
+
+	// Every format can send them.
+	bodies := map[string][]byte{
+		yamlContentType:    []byte("- id: \"1\"\n  version: 2\n- \"2\"\n"),
+		xmlContentType:     []byte("<ids><item><id>1</id><version>2</version></item><id>2</id></ids>"),
+		msgpackContentType: encodeMsgPackForTesting(t, []interface{}{map[string]interface{}{"id": "1", "version": 2}, "2"}),
+	}
+
+	for contentType, body := range bodies {
+		repository = newInMemoryCustomerRepository()
+		router = setupRouter()
+		sendRequestForTesting(router, "POST", "/customers/bulk", getMockedCustomers())
+		sendRequestForTesting(router, "PUT", "/customers/bulk", []customer{updatedCustomer})
+
+		writer = sendNegotiatedRequestForTesting(router, "DELETE", "/customers/bulk", contentType, gin.MIMEJSON, body)
+		assert.Equal(t, 207, writer.Code, contentType)
+		assert.Equal(t, []int{200, 200}, getBulkStatuses(getBulkResponseForTesting(t, writer).Results), contentType)
+	}
+}
+
+func TestChangeCustomersInBulkWhenIfMatchIsRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	requireIfMatch = true
+	router := setupRouter()
+
+	defer func() {
+		requireIfMatch = false
+		clearCustomers(nil)
+	}()
+
+	sendRequestForTesting(router, "POST", "/customers/bulk", getMockedCustomers())
+	updatedCustomer := getMockedUpdatedCustomerInformation()
+
+	writer := sendRequestForTesting(router, "PUT", "/customers/bulk", []bulkUpdateItem{{updatedCustomer, 0}, {updatedCustomer, 1}})
+	assert.Equal(t, 207, writer.Code)
+
+	got := getBulkResponseForTesting(t, writer)
+	assert.Equal(t, []int{428, 200}, getBulkStatuses(got.Results))
+	assert.Equal(t, bulkVersionRequiredDetail, got.Results[0].Problem.Detail)
+
+	writer = sendRequestForTesting(router, "DELETE", "/customers/bulk", []interface{}{"2", map[string]interface{}{"id": "2", "version": 1}})
+	assert.Equal(t, 207, writer.Code)
+	assert.Equal(t, []int{428, 200}, getBulkStatuses(getBulkResponseForTesting(t, writer).Results))
+}
+
+func TestChangeCustomersInBulkAtomically(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	defer clearCustomers(nil)
+
+	for name, wrappedRepository := range getRepositoriesForTesting(t) {
+		t.Run(name, func(t *testing.T) {
+			searchableRepository, err := newSearchableCustomerRepository(context.Background(), wrappedRepository)
+			assert.NoError(t, err)
+
+			repository = searchableRepository
+			router := setupRouter()
+
+			invalidCustomer := getMockedCustomersForFiltering()[1]
+			invalidCustomer.Name = ""
+
+			writer := sendRequestForTesting(router, "POST", "/customers/bulk?atomic=true",
+				[]customer{getMockedCustomersForFiltering()[0], invalidCustomer, getMockedCustomersForFiltering()[2]})
+			assert.Equal(t, 422, writer.Code)
+
+			var got problem
+			assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &got))
+
+			assert.Equal(t, bulkRolledBackProblemType, got.Type)
+			assert.Equal(t, "1 of 3 items failed", got.Detail)
+			assert.Equal(t, []int{424, 400, 424}, getBulkStatuses(got.Results))
+
+			// Nothing was stored, nor indexed.
+			customers, _ := listAllCustomers(context.Background(), repository)
+			assert.Empty(t, customers)
+
+			results, _, _ := searchableRepository.Search(context.Background(), searchQuery{Text: getMockedCustomersForFiltering()[0].Name, Limit: 10})
+			assert.Empty(t, results)
+
+			writer = sendRequestForTesting(router, "POST", "/customers/bulk?atomic=true", getMockedCustomersForFiltering())
+			assert.Equal(t, 201, writer.Code)
+			assert.Equal(t, len(getMockedCustomersForFiltering()), getBulkResponseForTesting(t, writer).Succeeded)
+
+			writer = sendRequestForTesting(router, "DELETE", "/customers/bulk?atomic=true", []string{"1", "unknown"})
+			assert.Equal(t, 422, writer.Code)
+
+			_, err = repository.Get(context.Background(), "1")
+			assert.NoError(t, err)
+
+			results, _, _ = searchableRepository.Search(context.Background(), searchQuery{Text: getMockedCustomersForFiltering()[0].Name, Limit: 10})
+			assert.NotEmpty(t, results)
+		})
+	}
+}
+
+func TestChangeCustomersInBulkWithInvalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	tests := []struct {
+		method       string
+		path         string
+		body         interface{}
+		expectedType string
+	}{
+		{"POST", "/customers/bulk", []customer{}, invalidBodyProblemType},
+		{"POST", "/customers/bulk", getMockedCustomer(), invalidBodyProblemType},
+		{"PUT", "/customers/bulk", make([]customer, maxBulkItems+1), invalidBodyProblemType},
+		{"DELETE", "/customers/bulk", []int{1}, invalidBodyProblemType},
+		{"DELETE", "/customers/bulk", []interface{}{map[string]interface{}{"id": "1", "etag": "1"}}, invalidBodyProblemType},
+		{"DELETE", "/customers/bulk?atomic=maybe", []string{"1"}, invalidQueryProblemType},
+	}
+
+	for _, test := range tests {
+		writer := sendRequestForTesting(router, test.method, test.path, test.body)
+
+		assert.Equal(t, 400, writer.Code, test.path)
+		assert.Equal(t, test.expectedType, getProblemFromResponse(t, writer)["type"], test.path)
+	}
+}
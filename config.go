@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v2"
+)
+
+// config is the configuration of the server. loadConfig reads it from, in
+// increasing order of precedence, its defaults, a YAML file, the
+// environment variables and the command line flags.
+type config struct {
+	// Mode is the mode of gin: debug, release or test.
+	Mode             string        `yaml:"mode"`
+	HTTP             httpConfig    `yaml:"http"`
+	GRPC             grpcConfig    `yaml:"grpc"`
+	Storage          storageConfig `yaml:"storage"`
+	IDs              idConfig      `yaml:"ids"`
+	RequireIfMatch   bool          `yaml:"require_if_match"`
+	ValidateRequests bool          `yaml:"validate_requests"`
+	CORS             corsConfig    `yaml:"cors"`
+	Auth             authConfig    `yaml:"auth"`
+	// ShutdownTimeout is how long the requests in flight are waited for
+	// when the server is stopped.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// httpConfig is where the HTTP API listens and how long its connections
+// can take. A timeout of 0 means there's none.
+type httpConfig struct {
+	Address      string        `yaml:"address"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+}
+
+// grpcConfig is where CustomerService listens. An empty address doesn't
+// serve it.
+type grpcConfig struct {
+	Address string `yaml:"address"`
+}
+
+// storageConfig selects the repository. DSN is the database of the
+// backends that have one, which for SQLite is the path of its file.
+type storageConfig struct {
+	Backend      string `yaml:"backend"`
+	DSN          string `yaml:"dsn"`
+	UniqueEmails bool   `yaml:"unique_emails"`
+}
+
+// idConfig has the names of the ID policy, assignment and generator.
+type idConfig struct {
+	Policy     string `yaml:"policy"`
+	Assignment string `yaml:"assignment"`
+	Generator  string `yaml:"generator"`
+}
+
+// corsConfig lets browsers on AllowedOrigins call the API. No origin is
+// allowed when it's empty, and "*" allows every one.
+type corsConfig struct {
+	AllowedOrigins []string      `yaml:"allowed_origins"`
+	AllowedMethods []string      `yaml:"allowed_methods"`
+	AllowedHeaders []string      `yaml:"allowed_headers"`
+	MaxAge         time.Duration `yaml:"max_age"`
+}
+
+// authConfig has the API keys clients must send as bearer tokens. The API
+// is open when there's none.
+type authConfig struct {
+	APIKeys []string `yaml:"api_keys"`
+}
+
+func newDefaultConfig() config {
+	return config{
+		Mode: gin.DebugMode,
+		HTTP: httpConfig{
+			Address:      ":8080",
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: time.Minute,
+			IdleTimeout:  2 * time.Minute,
+		},
+		GRPC:    grpcConfig{Address: ":9090"},
+		Storage: storageConfig{Backend: "memory", DSN: "customers.db"},
+		IDs:     idConfig{Policy: "opaque", Assignment: string(optionalIdAssignment), Generator: "ulid"},
+		CORS: corsConfig{
+			AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+			AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "If-Match", "If-None-Match"},
+			MaxAge:         10 * time.Minute,
+		},
+		// Docker kills the containers that haven't stopped 10 seconds after
+		// being asked to.
+		ShutdownTimeout: 8 * time.Second,
+	}
+}
+
+// configSetting is a field of config that can be set with an environment
+// variable or a flag. The flag is named after Key, as in -http-address for
+// http.address, and the first of Environment that's set is used. Field
+// returns a pointer to the field.
+type configSetting struct {
+	Key         string
+	Environment []string
+	Usage       string
+	Field       func(*config) interface{}
+}
+
+// configFileEnvironment and configFileFlag name the YAML file to read.
+const (
+	configFileEnvironment = "CUSTOMERS_CONFIG"
+	configFileFlag        = "config"
+)
+
+var configSettings = []configSetting{
+	{"mode", []string{"CUSTOMERS_MODE", gin.EnvGinMode}, "mode of gin: debug, release or test",
+		func(c *config) interface{} { return &c.Mode }},
+	{"http.address", []string{"CUSTOMERS_HTTP_ADDRESS"}, "address of the HTTP API",
+		func(c *config) interface{} { return &c.HTTP.Address }},
+	{"http.read_timeout", []string{"CUSTOMERS_HTTP_READ_TIMEOUT"}, "longest time to read a request",
+		func(c *config) interface{} { return &c.HTTP.ReadTimeout }},
+	{"http.write_timeout", []string{"CUSTOMERS_HTTP_WRITE_TIMEOUT"}, "longest time to write a response",
+		func(c *config) interface{} { return &c.HTTP.WriteTimeout }},
+	{"http.idle_timeout", []string{"CUSTOMERS_HTTP_IDLE_TIMEOUT"}, "longest time to keep an idle connection",
+		func(c *config) interface{} { return &c.HTTP.IdleTimeout }},
+	{"grpc.address", []string{"CUSTOMERS_GRPC_ADDRESS"}, "address of the gRPC service, or empty to not serve it",
+		func(c *config) interface{} { return &c.GRPC.Address }},
+	{"storage.backend", []string{"CUSTOMERS_STORAGE"}, "where customers are kept: memory or sqlite",
+		func(c *config) interface{} { return &c.Storage.Backend }},
+	{"storage.dsn", []string{"CUSTOMERS_STORAGE_DSN", "CUSTOMERS_SQLITE_PATH"}, "database of the storage (the file, for sqlite)",
+		func(c *config) interface{} { return &c.Storage.DSN }},
+	{"storage.unique_emails", []string{"CUSTOMERS_UNIQUE_EMAILS"}, "reject customers whose email is in use",
+		func(c *config) interface{} { return &c.Storage.UniqueEmails }},
+	{"ids.policy", []string{"CUSTOMERS_ID_POLICY"}, "IDs accepted: opaque, uuid, ulid or integer",
+		func(c *config) interface{} { return &c.IDs.Policy }},
+	{"ids.assignment", []string{"CUSTOMERS_ID_ASSIGNMENT"}, "who chooses the IDs: optional, client or server",
+		func(c *config) interface{} { return &c.IDs.Assignment }},
+	{"ids.generator", []string{"CUSTOMERS_ID_GENERATOR"}, "how IDs are generated: ulid, uuid or sequence",
+		func(c *config) interface{} { return &c.IDs.Generator }},
+	{"require_if_match", []string{"CUSTOMERS_REQUIRE_IF_MATCH"}, "require If-Match to change a customer",
+		func(c *config) interface{} { return &c.RequireIfMatch }},
+	{"validate_requests", []string{"CUSTOMERS_VALIDATE_REQUESTS"}, "check requests against the OpenAPI document",
+		func(c *config) interface{} { return &c.ValidateRequests }},
+	{"cors.allowed_origins", []string{"CUSTOMERS_CORS_ALLOWED_ORIGINS"}, "comma separated origins allowed by CORS, or *",
+		func(c *config) interface{} { return &c.CORS.AllowedOrigins }},
+	{"cors.allowed_methods", []string{"CUSTOMERS_CORS_ALLOWED_METHODS"}, "comma separated methods allowed by CORS",
+		func(c *config) interface{} { return &c.CORS.AllowedMethods }},
+	{"cors.allowed_headers", []string{"CUSTOMERS_CORS_ALLOWED_HEADERS"}, "comma separated request headers allowed by CORS",
+		func(c *config) interface{} { return &c.CORS.AllowedHeaders }},
+	{"cors.max_age", []string{"CUSTOMERS_CORS_MAX_AGE"}, "how long browsers can cache the CORS preflight",
+		func(c *config) interface{} { return &c.CORS.MaxAge }},
+	{"auth.api_keys", []string{"CUSTOMERS_AUTH_API_KEYS"}, "comma separated API keys, sent as bearer tokens",
+		func(c *config) interface{} { return &c.Auth.APIKeys }},
+	{"shutdown_timeout", []string{"CUSTOMERS_SHUTDOWN_TIMEOUT"}, "longest time to wait for the requests in flight when stopping",
+		func(c *config) interface{} { return &c.ShutdownTimeout }},
+}
+
+// emptyConfigSettings are the settings where an empty value means
+// something, so environment variables set to an empty string aren't
+// ignored for them: no gRPC service, no CORS origin and no API key.
+var emptyConfigSettings = map[string]bool{"grpc.address": true, "cors.allowed_origins": true, "auth.api_keys": true}
+
+// loadConfig reads the configuration from the defaults, the YAML file
+// named by -config or CUSTOMERS_CONFIG, the environment variables found
+// with lookupEnvironment and the flags in arguments, each one overriding
+// the previous ones, and validates it.
+func loadConfig(arguments []string, lookupEnvironment func(string) (string, bool)) (config, error) {
+	loadedConfig := newDefaultConfig()
+
+	flags := flag.NewFlagSet("customers-api", flag.ContinueOnError)
+	configFile := flags.String(configFileFlag, "", "YAML file with the configuration")
+	flagValues := map[string]*configFlag{}
+
+	for _, setting := range configSettings {
+		_, isBool := setting.Field(&loadedConfig).(*bool)
+		flagValues[setting.Key] = &configFlag{isBool: isBool}
+		flags.Var(flagValues[setting.Key], getConfigFlagName(setting.Key), setting.Usage)
+	}
+
+	if err := flags.Parse(arguments); err != nil {
+		return config{}, err
+	}
+
+	if *configFile == "" {
+		*configFile, _ = lookupEnvironment(configFileEnvironment)
+	}
+
+	if *configFile != "" {
+		contents, err := os.ReadFile(*configFile)
+
+		if err != nil {
+			return config{}, err
+		}
+
+		if err := yaml.UnmarshalStrict(contents, &loadedConfig); err != nil {
+			return config{}, fmt.Errorf("%s: %w", *configFile, err)
+		}
+	}
+
+	for _, setting := range configSettings {
+		for _, name := range setting.Environment {
+			if value, isSet := lookupEnvironment(name); isSet && (value != "" || emptyConfigSettings[setting.Key]) {
+				if err := setConfigField(setting.Field(&loadedConfig), value); err != nil {
+					return config{}, fmt.Errorf("invalid %s: %w", name, err)
+				}
+
+				break
+			}
+		}
+	}
+
+	for _, setting := range configSettings {
+		if value := flagValues[setting.Key]; value.isSet {
+			if err := setConfigField(setting.Field(&loadedConfig), value.value); err != nil {
+				return config{}, fmt.Errorf("invalid -%s: %w", getConfigFlagName(setting.Key), err)
+			}
+		}
+	}
+
+	if err := loadedConfig.validate(); err != nil {
+		return config{}, err
+	}
+
+	return loadedConfig, nil
+}
+
+// validate returns every problem found with the configuration.
+func (c config) validate() error {
+	var problems []error
+
+	addProblem := func(key string, format string, arguments ...interface{}) {
+		problems = append(problems, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, arguments...)))
+	}
+
+	if c.Mode != gin.DebugMode && c.Mode != gin.ReleaseMode && c.Mode != gin.TestMode {
+		addProblem("mode", "unknown mode %q", c.Mode)
+	}
+
+	if c.HTTP.Address == "" {
+		addProblem("http.address", "cannot be empty")
+	}
+
+	for key, timeout := range map[string]time.Duration{
+		"http.read_timeout":  c.HTTP.ReadTimeout,
+		"http.write_timeout": c.HTTP.WriteTimeout,
+		"http.idle_timeout":  c.HTTP.IdleTimeout,
+		"cors.max_age":       c.CORS.MaxAge,
+		"shutdown_timeout":   c.ShutdownTimeout,
+	} {
+		if timeout < 0 {
+			addProblem(key, "cannot be negative")
+		}
+	}
+
+	switch c.Storage.Backend {
+	case "memory":
+	case "sqlite":
+		if c.Storage.DSN == "" {
+			addProblem("storage.dsn", "cannot be empty with the sqlite backend")
+		}
+	default:
+		addProblem("storage.backend", "unknown backend %q", c.Storage.Backend)
+	}
+
+	policy, policyErr := newIdPolicy(c.IDs.Policy)
+
+	if policyErr != nil {
+		addProblem("ids.policy", "%v", policyErr)
+	}
+
+	assignment, err := parseIdAssignment(c.IDs.Assignment)
+
+	if err != nil {
+		addProblem("ids.assignment", "%v", err)
+	}
+
+	// The generated IDs must be accepted by the rest of the routes. An
+	// empty repository is used, so no ID is wasted. Clients choose every
+	// ID with the client assignment, so nothing is generated.
+	if generator, err := newIdGenerator(context.Background(), c.IDs.Generator, newInMemoryCustomerRepository()); err != nil {
+		addProblem("ids.generator", "%v", err)
+	} else if policyErr == nil && assignment != clientIdAssignment {
+		if err := policy.Validate(generator.Generate()); err != nil {
+			addProblem("ids.generator", "the %s generator doesn't follow the %s policy: %v", c.IDs.Generator, c.IDs.Policy, err)
+		}
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if originUrl, err := url.Parse(origin); origin != "*" && (err != nil || originUrl.Scheme == "" || originUrl.Host == "") {
+			addProblem("cors.allowed_origins", "%q is not an origin, like https://example.com", origin)
+		}
+	}
+
+	for _, method := range c.CORS.AllowedMethods {
+		if method != strings.ToUpper(method) || strings.ContainsAny(method, " ,") {
+			addProblem("cors.allowed_methods", "%q is not an HTTP method", method)
+		}
+	}
+
+	for _, key := range c.Auth.APIKeys {
+		if key == "" || strings.ContainsAny(key, " \t,") {
+			addProblem("auth.api_keys", "keys cannot be empty nor have spaces or commas")
+			break
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+func getConfigFlagName(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+// setConfigField parses value into field, which is a pointer to a field
+// of config. Lists are comma separated.
+func setConfigField(field interface{}, value string) error {
+	switch field := field.(type) {
+	case *string:
+		*field = value
+	case *bool:
+		parsed, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return err
+		}
+
+		*field = parsed
+	case *time.Duration:
+		parsed, err := time.ParseDuration(value)
+
+		if err != nil {
+			return err
+		}
+
+		*field = parsed
+	case *[]string:
+		*field = []string{}
+
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*field = append(*field, item)
+			}
+		}
+	default:
+		panic(fmt.Sprintf("unsupported config field %T", field))
+	}
+
+	return nil
+}
+
+// configFlag keeps the value of a flag until the file and the environment
+// variables, which it overrides, are read.
+type configFlag struct {
+	value  string
+	isSet  bool
+	isBool bool
+}
+
+func (f *configFlag) String() string {
+	return f.value
+}
+
+func (f *configFlag) Set(value string) error {
+	f.value = value
+	f.isSet = true
+
+	return nil
+}
+
+// IsBoolFlag lets boolean flags be sent without a value, as in
+// -require-if-match.
+func (f *configFlag) IsBoolFlag() bool {
+	return f.isBool
+}
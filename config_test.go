@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getEnvironmentForTesting(variables map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, isSet := variables[name]
+		return value, isSet
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	got, err := loadConfig(nil, getEnvironmentForTesting(nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, newDefaultConfig(), got)
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	contents := `
+mode: release
+http:
+  address: ":1000"
+  read_timeout: 5s
+storage:
+  backend: sqlite
+  dsn: file.db
+cors:
+  allowed_origins: ["https://example.com"]
+`
+
+	if err := os.WriteFile(file, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The environment overrides the file, and the flags override both.
+	got, err := loadConfig([]string{"-http-address", ":3000", "-require-if-match", "-auth-api-keys", "first,second"},
+		getEnvironmentForTesting(map[string]string{
+			"CUSTOMERS_CONFIG":       file,
+			"CUSTOMERS_HTTP_ADDRESS": ":2000",
+			"CUSTOMERS_SQLITE_PATH":  "environment.db",
+			"CUSTOMERS_ID_POLICY":    "integer",
+			"CUSTOMERS_ID_GENERATOR": "sequence",
+		}))
+
+	assert.NoError(t, err)
+
+	want := newDefaultConfig()
+	want.Mode = "release"
+	want.HTTP.Address = ":3000"
+	want.HTTP.ReadTimeout = 5 * time.Second
+	want.Storage = storageConfig{Backend: "sqlite", DSN: "environment.db"}
+	want.IDs.Policy = "integer"
+	want.IDs.Generator = "sequence"
+	want.RequireIfMatch = true
+	want.CORS.AllowedOrigins = []string{"https://example.com"}
+	want.Auth.APIKeys = []string{"first", "second"}
+
+	assert.Equal(t, want, got)
+
+	// The file can also be given with a flag, and its keys are checked.
+	if err := os.WriteFile(file, []byte("http:\n  adress: \":1000\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = loadConfig([]string{"-config", file}, getEnvironmentForTesting(nil))
+	assert.ErrorContains(t, err, "field adress not found")
+}
+
+func TestLoadConfigWithEmptyEnvironmentVariables(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+
+	if err := os.WriteFile(file, []byte("auth:\n  api_keys: [\"key\"]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := loadConfig(nil, getEnvironmentForTesting(map[string]string{
+		"CUSTOMERS_CONFIG":        file,
+		"CUSTOMERS_GRPC_ADDRESS":  "",
+		"CUSTOMERS_AUTH_API_KEYS": "",
+		"CUSTOMERS_HTTP_ADDRESS":  "",
+		"CUSTOMERS_MODE":          "",
+	}))
+
+	assert.NoError(t, err)
+
+	// An empty address doesn't serve gRPC and an empty list clears the
+	// file's, but settings that can't be empty keep their values.
+	want := newDefaultConfig()
+	want.GRPC.Address = ""
+	want.Auth.APIKeys = []string{}
+
+	assert.Equal(t, want, got)
+}
+
+func TestLoadConfigValidation(t *testing.T) {
+	_, err := loadConfig([]string{"-mode", "staging", "-storage-backend", "sqlite", "-storage-dsn", "",
+		"-http-idle-timeout", "-1s", "-ids-policy", "serial", "-cors-allowed-origins", "example.com"},
+		getEnvironmentForTesting(nil))
+
+	assert.ErrorContains(t, err, `mode: unknown mode "staging"`)
+	assert.ErrorContains(t, err, "storage.dsn: cannot be empty with the sqlite backend")
+	assert.ErrorContains(t, err, "http.idle_timeout: cannot be negative")
+	assert.ErrorContains(t, err, `ids.policy: unknown ID policy "serial"`)
+	assert.ErrorContains(t, err, `cors.allowed_origins: "example.com" is not an origin`)
+
+	_, err = loadConfig(nil, getEnvironmentForTesting(map[string]string{"CUSTOMERS_HTTP_READ_TIMEOUT": "soon"}))
+	assert.ErrorContains(t, err, "invalid CUSTOMERS_HTTP_READ_TIMEOUT")
+
+	_, err = loadConfig([]string{"-unique-emails"}, getEnvironmentForTesting(nil))
+	assert.ErrorContains(t, err, "flag provided but not defined")
+}
+
+func TestLoadConfigChecksGeneratedIds(t *testing.T) {
+	_, err := loadConfig(nil, getEnvironmentForTesting(map[string]string{"CUSTOMERS_ID_POLICY": "uuid"}))
+	assert.ErrorContains(t, err, "ids.generator: the ulid generator doesn't follow the uuid policy")
+
+	// Nothing is generated when the clients choose every ID.
+	_, err = loadConfig(nil, getEnvironmentForTesting(map[string]string{
+		"CUSTOMERS_ID_POLICY":     "uuid",
+		"CUSTOMERS_ID_ASSIGNMENT": "client",
+	}))
+	assert.NoError(t, err)
+
+	for policy, generator := range map[string]string{"opaque": "ulid", "uuid": "uuid", "ulid": "ulid", "integer": "sequence"} {
+		_, err = loadConfig([]string{"-ids-policy", policy, "-ids-generator", generator}, getEnvironmentForTesting(nil))
+		assert.NoError(t, err, policy)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsSettings are the origins, methods and headers that browsers on other
+// sites can use. It's replaced on startup by the configured ones.
+var corsSettings = newDefaultConfig().CORS
+
+// corsExposedHeaders are the response headers of the API that scripts on
+// other origins can read.
+var corsExposedHeaders = []string{"Accept-Patch", "Deprecation", "ETag", "Link", "Location", "Sunset"}
+
+// corsMiddleware implements CORS for the allowed origins of settings. It
+// answers the preflight requests itself, so they don't reach the routes,
+// which don't have OPTIONS handlers.
+func corsMiddleware(settings corsConfig) gin.HandlerFunc {
+	allowedMethods := strings.Join(settings.AllowedMethods, ", ")
+	allowedHeaders := strings.Join(settings.AllowedHeaders, ", ")
+	exposedHeaders := strings.Join(corsExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(settings.MaxAge.Seconds()))
+
+	return func(context *gin.Context) {
+		context.Writer.Header().Add("Vary", "Origin")
+		origin := context.GetHeader("Origin")
+
+		if origin == "" || !isCorsOriginAllowed(settings, origin) {
+			context.Next()
+			return
+		}
+
+		context.Header("Access-Control-Allow-Origin", origin)
+		context.Header("Access-Control-Expose-Headers", exposedHeaders)
+
+		if context.Request.Method == http.MethodOptions && context.GetHeader("Access-Control-Request-Method") != "" {
+			context.Header("Access-Control-Allow-Methods", allowedMethods)
+			context.Header("Access-Control-Allow-Headers", allowedHeaders)
+			context.Header("Access-Control-Max-Age", maxAge)
+			context.AbortWithStatus(http.StatusNoContent)
+
+			return
+		}
+
+		context.Next()
+	}
+}
+
+func isCorsOriginAllowed(settings corsConfig, origin string) bool {
+	for _, allowedOrigin := range settings.AllowedOrigins {
+		if allowedOrigin == "*" || strings.EqualFold(allowedOrigin, origin) {
+			return true
+		}
+	}
+
+	return false
+}
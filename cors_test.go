@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCorsMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	corsSettings.AllowedOrigins = []string{"https://example.com"}
+
+	defer func() {
+		corsSettings = newDefaultConfig().CORS
+		clearCustomers(nil)
+	}()
+
+	router := setupRouter()
+
+	// Preflight requests are answered without reaching the routes.
+	request := httptest.NewRequest("OPTIONS", "/v1/customer/1", nil)
+	request.Header.Set("Origin", "https://example.com")
+	request.Header.Set("Access-Control-Request-Method", "PUT")
+	writer := httptest.NewRecorder()
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, 204, writer.Code)
+	assert.Equal(t, "https://example.com", writer.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST, PUT, PATCH, DELETE", writer.Header().Get("Access-Control-Allow-Methods"))
+	assert.Contains(t, writer.Header().Get("Access-Control-Allow-Headers"), "If-Match")
+	assert.Equal(t, "600", writer.Header().Get("Access-Control-Max-Age"))
+
+	request = httptest.NewRequest("GET", "/v1/customers", nil)
+	request.Header.Set("Origin", "https://example.com")
+	writer = httptest.NewRecorder()
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, 200, writer.Code)
+	assert.Equal(t, "https://example.com", writer.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, writer.Header().Get("Access-Control-Expose-Headers"), "ETag")
+	assert.Contains(t, writer.Header().Values("Vary"), "Origin")
+
+	// Other origins don't get any CORS header.
+	request = httptest.NewRequest("OPTIONS", "/v1/customer/1", nil)
+	request.Header.Set("Origin", "https://attacker.example")
+	request.Header.Set("Access-Control-Request-Method", "DELETE")
+	writer = httptest.NewRecorder()
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, 405, writer.Code)
+	assert.Empty(t, writer.Header().Get("Access-Control-Allow-Origin"))
+}
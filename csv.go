@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	csvContentType = "text/csv"
+
+	// maxImportedRows is the highest number of customers of an imported
+	// CSV file.
+	maxImportedRows = 10000
+
+	// maxImportedBytes is the size of the largest CSV file accepted, which
+	// leaves about 1 KiB for each of maxImportedRows.
+	maxImportedBytes = 10 << 20
+)
+
+// customerCSVColumns are the columns of an exported CSV file, which are
+// also the ones accepted when importing one.
+var customerCSVColumns = []string{customerIdField, "name", "surname", "email", customerBirthdateField}
+
+// importCustomers adds the customers of the CSV file received in the
+// request body, as postCustomers does. The first row has the names of the
+// columns, which are matched to the fields of a customer ignoring case and
+// order.
+func importCustomers(context *gin.Context) {
+	if context.ContentType() != csvContentType {
+		unsupportedProblem := newStatusProblem(http.StatusUnsupportedMediaType)
+		unsupportedProblem.Detail = "Content-Type must be " + csvContentType
+		abortWithProblem(unsupportedProblem, context)
+		return
+	}
+
+	body := http.MaxBytesReader(context.Writer, context.Request.Body, maxImportedBytes)
+	newCustomers, rows, err := readCustomersCSV(body, maxImportedRows)
+
+	var maxBytesError *http.MaxBytesError
+
+	if errors.As(err, &maxBytesError) {
+		tooLargeProblem := newStatusProblem(http.StatusRequestEntityTooLarge)
+		tooLargeProblem.Detail = fmt.Sprintf("The CSV file can't be larger than %d bytes", maxBytesError.Limit)
+		abortWithProblem(tooLargeProblem, context)
+		return
+	} else if err != nil {
+		abortWithProblem(newInvalidBodyProblem(err), context)
+		return
+	}
+
+	runBulkOperation(len(newCustomers), maxImportedRows, http.StatusCreated, newImportOperation(newCustomers, rows), context)
+}
+
+// newImportOperation creates the customers as newBulkCreateOperation does,
+// adding to each result the row where the customer was found.
+func newImportOperation(newCustomers []customer, rows []int) bulkOperation {
+	createOperation := newBulkCreateOperation(newCustomers)
+
+	return func(ctx context.Context, targetRepository CustomerRepository, index int) bulkItemResult {
+		result := createOperation(ctx, targetRepository, index)
+		result.Row = rows[index]
+
+		return result
+	}
+}
+
+// readCustomersCSV reads the customers of a CSV file, along with the line
+// where each of them starts. It stops after maxRows+1 customers, which is
+// enough to tell the file has too many, without reading the rest.
+func readCustomersCSV(input io.Reader, maxRows int) ([]customer, []int, error) {
+	reader := csv.NewReader(input)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+
+	if errors.Is(err, io.EOF) {
+		return nil, nil, errors.New("the CSV file is empty")
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	fields, err := mapCSVHeader(header)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newCustomers := []customer{}
+	rows := []int{}
+
+	for len(newCustomers) <= maxRows {
+		record, err := reader.Read()
+
+		if errors.Is(err, io.EOF) {
+			return newCustomers, rows, nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		var newCustomer customer
+
+		for i, field := range fields {
+			setCustomerField(&newCustomer, field, unescapeCSVFormula(strings.TrimSpace(record[i])))
+		}
+
+		row, _ := reader.FieldPos(0)
+		newCustomers = append(newCustomers, newCustomer)
+		rows = append(rows, row)
+	}
+
+	return newCustomers, rows, nil
+}
+
+// mapCSVHeader returns the field of a customer stored in each column.
+func mapCSVHeader(header []string) ([]string, error) {
+	fields := make([]string, len(header))
+	mappedColumns := map[string]bool{}
+
+	for i, column := range header {
+		// Spreadsheets often start their UTF-8 files with a byte order
+		// mark.
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+
+		field := strings.ToLower(strings.TrimSpace(column))
+
+		if _, isFieldKnown := filterOperatorsByField[field]; !isFieldKnown {
+			return nil, fmt.Errorf("unknown column %q, the columns must be %s", column, strings.Join(customerCSVColumns, ", "))
+		}
+
+		if mappedColumns[field] {
+			return nil, fmt.Errorf("column %q is repeated", column)
+		}
+
+		mappedColumns[field] = true
+		fields[i] = field
+	}
+
+	return fields, nil
+}
+
+// csvFormulaPrefixes are the characters that make spreadsheets read a cell
+// as a formula.
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// escapeCSVFormula prefixes with ' the values that spreadsheets would run
+// as formulas, like =HYPERLINK(...), so exported customers can't inject
+// them. Values that already start with ' followed by a formula are
+// prefixed too, so unescapeCSVFormula gives back every value as it was.
+func escapeCSVFormula(value string) string {
+	if isCSVFormula(value) {
+		return "'" + value
+	}
+
+	return value
+}
+
+// unescapeCSVFormula is the counterpart of escapeCSVFormula, so exported
+// files can be imported back.
+func unescapeCSVFormula(value string) string {
+	if escaped, isEscaped := strings.CutPrefix(value, "'"); isEscaped && isCSVFormula(escaped) {
+		return escaped
+	}
+
+	return value
+}
+
+func isCSVFormula(value string) bool {
+	if value == "" {
+		return false
+	}
+
+	if strings.ContainsRune(csvFormulaPrefixes, rune(value[0])) {
+		return true
+	}
+
+	return value[0] == '\'' && isCSVFormula(value[1:])
+}
+
+// setCustomerField is the counterpart of getCustomerField.
+func setCustomerField(newCustomer *customer, field string, value string) {
+	switch field {
+	case customerIdField:
+		newCustomer.ID = value
+	case "name":
+		newCustomer.Name = value
+	case "surname":
+		newCustomer.Surname = value
+	case "email":
+		newCustomer.Email = value
+	case customerBirthdateField:
+		newCustomer.Birthdate = value
+	default:
+		panic(fmt.Sprintf("unknown customer field %q", field))
+	}
+}
+
+// exportCustomers responds with a CSV file of the customers, which can be
+// filtered and sorted with the same query parameters as getCustomers. The
+// file is written a page at a time, so the customers are never all in
+// memory.
+func exportCustomers(context *gin.Context) {
+	parameters := context.Request.URL.Query()
+	sortKeys, fieldErrors := parseSort(parameters)
+	filters, filterErrors := parseFilters(parameters)
+	fieldErrors = append(fieldErrors, filterErrors...)
+
+	if len(fieldErrors) > 0 {
+		abortWithProblem(newInvalidQueryProblem(fieldErrors), context)
+		return
+	}
+
+	context.Header("Content-Type", csvContentType+"; charset=utf-8")
+	context.Header("Content-Disposition", `attachment; filename="customers.csv"`)
+
+	writer := csv.NewWriter(context.Writer)
+	record := make([]string, len(customerCSVColumns))
+
+	writeCustomer := func(storedCustomer customer) error {
+		for i, field := range customerCSVColumns {
+			record[i] = escapeCSVFormula(getCustomerField(storedCustomer, field))
+		}
+
+		return writer.Write(record)
+	}
+
+	flush := func() error {
+		writer.Flush()
+		return writer.Error()
+	}
+
+	if err := writer.Write(customerCSVColumns); err != nil {
+		context.Error(err)
+		return
+	}
+
+	streamMatchingCustomers(listQuery{Filters: filters, Sort: sortKeys}, writeCustomer, flush, context)
+}
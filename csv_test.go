@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func sendCSVRequestForTesting(router *gin.Engine, path string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest("POST", path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "text/csv; charset=utf-8")
+
+	writer := httptest.NewRecorder()
+	router.ServeHTTP(writer, request)
+
+	return writer
+}
+
+func TestImportCustomers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	// The columns can be in any order and case, and the values can span
+	// several lines.
+	body := "\ufeffEmail, Name,surname,birthdate,ID\n" +
+		"augusto.giavedoni@gmail.com,Augusto,Giavedoni,2000-02-20,1\n" +
+		"not an email,\"John\nJonathan\",Wick,1964-09-02,2\n" +
+		"helen.wick@gmail.com,Helen,Wick,1970-01-01,1\n"
+
+	writer := sendCSVRequestForTesting(router, "/customers/import", body)
+	assert.Equal(t, 207, writer.Code)
+
+	got := getBulkResponseForTesting(t, writer)
+
+	assert.Equal(t, []int{201, 400, 409}, getBulkStatuses(got.Results))
+	assert.Equal(t, []int{2, 3, 5}, []int{got.Results[0].Row, got.Results[1].Row, got.Results[2].Row})
+	assert.Equal(t, getMockedCustomer(), *got.Results[0].Customer)
+	assert.Equal(t, []fieldError{{"email", invalidFieldErrorCode, "Email is not valid"}}, got.Results[1].Problem.Errors)
+
+	customers, _ := listAllCustomers(context.Background(), repository)
+	assert.Len(t, customers, 1)
+}
+
+func TestImportCustomersAsDryRun(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	body := "id,name,surname,email,birthdate\n" +
+		"1,Augusto,Giavedoni,augusto.giavedoni@gmail.com,1996-12-16\n" +
+		"1,Augusto,Giavedoni,augusto.giavedoni@gmail.com,1996-12-16\n"
+
+	// A dry run sees the changes of the previous rows, but keeps none.
+	writer := sendCSVRequestForTesting(router, "/customers/import?dry_run=true", body)
+	assert.Equal(t, 207, writer.Code)
+	assert.Equal(t, []int{201, 409}, getBulkStatuses(getBulkResponseForTesting(t, writer).Results))
+
+	customers, _ := listAllCustomers(context.Background(), repository)
+	assert.Empty(t, customers)
+
+	writer = sendCSVRequestForTesting(router, "/customers/import?atomic=true", body)
+	assert.Equal(t, 422, writer.Code)
+
+	var rolledBack problem
+	assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &rolledBack))
+	assert.Equal(t, []int{424, 409}, getBulkStatuses(rolledBack.Results))
+	assert.Equal(t, 3, rolledBack.Results[1].Row)
+
+	customers, _ = listAllCustomers(context.Background(), repository)
+	assert.Empty(t, customers)
+}
+
+func TestImportCustomersWithInvalidFile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"empty", ""},
+		{"only header", "id,name\n"},
+		{"unknown column", "id,name,phone\n1,John,555\n"},
+		{"repeated column", "id,name,Name\n1,John,John\n"},
+		{"wrong number of fields", "id,name\n1,John\n2\n"},
+	}
+
+	for _, test := range tests {
+		writer := sendCSVRequestForTesting(router, "/customers/import", test.body)
+
+		assert.Equal(t, 400, writer.Code, test.name)
+		assert.Equal(t, invalidBodyProblemType, getProblemFromResponse(t, writer)["type"], test.name)
+	}
+
+	writer := sendRequestForTesting(router, "POST", "/customers/import", getMockedCustomers())
+	assert.Equal(t, 415, writer.Code)
+}
+
+func TestImportCustomersWithTooLargeFile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	row := "1,Augusto,Giavedoni,augusto.giavedoni@gmail.com,1996-12-16\n"
+
+	writer := sendCSVRequestForTesting(router, "/customers/import",
+		"id,name,surname,email,birthdate\n"+strings.Repeat(row, maxImportedRows+1))
+	assert.Equal(t, 400, writer.Code)
+	assert.Equal(t, invalidBodyProblemType, getProblemFromResponse(t, writer)["type"])
+
+	writer = sendCSVRequestForTesting(router, "/customers/import",
+		"id,name,surname,email,birthdate\n1,"+strings.Repeat("A", maxImportedBytes)+",Giavedoni,augusto.giavedoni@gmail.com,1996-12-16\n")
+	assert.Equal(t, 413, writer.Code)
+
+	customers, _ := listAllCustomers(context.Background(), repository)
+	assert.Empty(t, customers)
+}
+
+func TestExportCustomersEscapesFormulas(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	formulaCustomer := getMockedCustomer()
+	formulaCustomer.Name = `=HYPERLINK("http://example.com","Click")`
+	formulaCustomer.Surname = "'+Wick"
+
+	_, err := repository.Create(context.Background(), formulaCustomer)
+	assert.NoError(t, err)
+
+	writer := sendRequestForTesting(router, "GET", "/customers/export", nil)
+	assert.Equal(t, 200, writer.Code)
+
+	lines := strings.Split(strings.TrimSpace(writer.Body.String()), "\n")
+	assert.Equal(t, `1,"'=HYPERLINK(""http://example.com"",""Click"")",''+Wick,augusto.giavedoni@gmail.com,2000-02-20`, lines[1])
+
+	// The file is imported back with the values as they were.
+	clearCustomers(nil)
+
+	writer = sendCSVRequestForTesting(router, "/customers/import", writer.Body.String())
+	assert.Equal(t, 207, writer.Code)
+
+	imported, err := repository.Get(context.Background(), "1")
+	assert.NoError(t, err)
+	assert.Equal(t, formulaCustomer.Name, imported.Name)
+	assert.Equal(t, formulaCustomer.Surname, imported.Surname)
+}
+
+func TestCSVFormulasAreEscapedReversibly(t *testing.T) {
+	for _, value := range []string{"", "John", "=1+1", "+1", "-1", "@SUM(A1)", "\t=1", "'", "'John", "'=1", "''-1", "John=1"} {
+		escaped := escapeCSVFormula(value)
+
+		if escaped != "" {
+			assert.NotContains(t, csvFormulaPrefixes, escaped[:1], value)
+		}
+
+		assert.Equal(t, value, unescapeCSVFormula(escaped), value)
+	}
+}
+
+func TestReadCustomersCSVStopsAfterTooManyRows(t *testing.T) {
+	body := "id\n1\n2\n3\n4\n5\n"
+
+	newCustomers, rows, err := readCustomersCSV(strings.NewReader(body), 2)
+	assert.NoError(t, err)
+	assert.Len(t, newCustomers, 3)
+	assert.Equal(t, []int{2, 3, 4}, rows)
+
+	// The rows after the limit aren't even parsed.
+	newCustomers, _, err = readCustomersCSV(strings.NewReader(body+"6,7\n"), 2)
+	assert.NoError(t, err)
+	assert.Len(t, newCustomers, 3)
+}
+
+func TestExportCustomers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	sendRequestForTesting(router, "POST", "/customers/bulk", getMockedCustomersForFiltering())
+
+	writer := sendRequestForTesting(router, "GET", "/customers/export?surname=Wick&sort=-name", nil)
+	assert.Equal(t, 200, writer.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", writer.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="customers.csv"`, writer.Header().Get("Content-Disposition"))
+
+	lines := strings.Split(strings.TrimSpace(writer.Body.String()), "\n")
+
+	assert.Equal(t, "id,name,surname,email,birthdate", lines[0])
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[1], "2,John,Wick,"))
+	assert.True(t, strings.HasPrefix(lines[2], "3,Helen,Wick,"))
+
+	// An exported file can be imported back.
+	writer = sendRequestForTesting(router, "GET", "/customers/export", nil)
+	clearCustomers(nil)
+
+	writer = sendCSVRequestForTesting(router, "/customers/import?atomic=true", writer.Body.String())
+	assert.Equal(t, 201, writer.Code)
+
+	customers, _ := listAllCustomers(context.Background(), repository)
+	assert.Equal(t, len(getMockedCustomersForFiltering()), len(customers))
+
+	writer = sendRequestForTesting(router, "GET", "/customers/export?phone=555", nil)
+	assert.Equal(t, 400, writer.Code)
+	assert.Equal(t, invalidQueryProblemType, getProblemFromResponse(t, writer)["type"])
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// cursor is the position of a page in the list of customers: the page
+// starts after the customer whose sorted fields have Values. Sort is the
+// order of the list, so the cursor isn't used with a different one. It's
+// sent to clients encoded, so they treat it as an opaque string.
+type cursor struct {
+	Sort   string   `json:"sort"`
+	Values []string `json:"values"`
+}
+
+func encodeCursor(position cursor) string {
+	jsonbytes, err := json.Marshal(position)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(jsonbytes)
+}
+
+// decodeCursor returns the position encoded by encodeCursor, or
+// ErrInvalidCursor.
+func decodeCursor(encodedCursor string) (cursor, error) {
+	var position cursor
+
+	jsonbytes, err := base64.RawURLEncoding.DecodeString(encodedCursor)
+
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(jsonbytes, &position); err != nil || len(position.Values) == 0 {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	return position, nil
+}
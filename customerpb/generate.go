@@ -0,0 +1,5 @@
+// Package customerpb has the protobuf messages and the gRPC service of the
+// customers, generated from customer.proto.
+package customerpb
+
+//go:generate buf generate --template buf.gen.yaml
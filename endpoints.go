@@ -1,34 +1,78 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"io"
 	"net/http"
+	"net/url"
 
 	"github.com/gin-gonic/gin"
 )
 
+// maxIdGenerationAttempts limits how many IDs postCustomer generates
+// before giving up on a customer whose generated IDs keep colliding.
+const maxIdGenerationAttempts = 10
+
 // postCustomer adds a customer from JSON received in the request body.
+// When the client doesn't send an ID, one is generated according to
+// customerIdAssignment.
 func postCustomer(context *gin.Context) {
 	var newCustomer customer
 
-	// Call BindJSON to bind the received JSON to
-	// newCustomer.
-	if err := context.BindJSON(&newCustomer); err != nil {
+	if !bindRequestBody(&newCustomer, context) {
 		return
 	}
 
-	isUserInformationValid := verifyCustomerInformation(newCustomer, context)
+	createdCustomer, err := createCustomer(context.Request.Context(), repository, newCustomer)
 
-	if !isUserInformationValid {
+	if err != nil {
+		respondWithRepositoryError(err, context)
 		return
 	}
 
-	// Add the new customer to the "database".
-	customers = append(customers, newCustomer)
-	context.IndentedJSON(http.StatusCreated, newCustomer)
+	versionPrefix, _ := splitApiVersion(context.FullPath())
+	context.Header("Location", versionPrefix+"/customer/"+url.PathEscape(createdCustomer.ID))
+	setCustomerETag(createdCustomer, context)
+	respond(http.StatusCreated, createdCustomer, context)
+}
+
+// createCustomer generates the ID of newCustomer if needed, validates it
+// and adds it to targetRepository. Invalid customers are reported with a
+// *problem.
+func createCustomer(ctx context.Context, targetRepository CustomerRepository, newCustomer customer) (customer, error) {
+	if newCustomer.ID != "" && customerIdAssignment == serverIdAssignment {
+		return customer{}, newValidationProblem([]fieldError{
+			{"id", notAllowedFieldErrorCode, "ID is assigned by the server and must not be sent"},
+		})
+	}
+
+	isIdGenerated := newCustomer.ID == "" && customerIdAssignment != clientIdAssignment
+
+	if isIdGenerated {
+		newCustomer.ID = customerIdGenerator.Generate()
+	}
+
+	if fieldErrors := validateCustomer(newCustomer); len(fieldErrors) > 0 {
+		return customer{}, newValidationProblem(fieldErrors)
+	}
+
+	// Add the new customer to the repository.
+	createdCustomer, err := targetRepository.Create(ctx, newCustomer)
+
+	// A generated ID can collide with one chosen by a client, so try
+	// again with a new one.
+	for attempt := 1; isIdGenerated && errors.Is(err, ErrConflict) && attempt < maxIdGenerationAttempts; attempt++ {
+		newCustomer.ID = customerIdGenerator.Generate()
+		createdCustomer, err = targetRepository.Create(ctx, newCustomer)
+	}
+
+	return createdCustomer, err
 }
 
 // getCustomerById locates the customer whose ID value matches the id
 // parameter sent by the client, then returns that customer as a response.
+// Nothing is returned if the If-None-Match header has its ETag.
 func getCustomerById(context *gin.Context) {
 	id := context.Param("id")
 
@@ -38,75 +82,262 @@ func getCustomerById(context *gin.Context) {
 		return
 	}
 
-	customer := searchCustomer(id)
+	customer, err := repository.Get(context.Request.Context(), id)
 
-	if customer.ID != "" {
-		context.IndentedJSON(http.StatusOK, customer)
+	if err != nil {
+		respondWithRepositoryError(err, context)
 		return
-	} else {
-		context.IndentedJSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
 	}
+
+	if !checkIfNoneMatch(customer, context) {
+		return
+	}
+
+	setCustomerETag(customer, context)
+	respond(http.StatusOK, customer, context)
 }
 
-// getCustomers responds with the list of all customers as JSON.
+// getCustomers responds with a page of customers as JSON. The limit and
+// cursor query parameters select the page, and the rest of them filter and
+// sort the customers. Clients that prefer NDJSON get every customer
+// instead, one per line, as they're read.
 func getCustomers(context *gin.Context) {
-	context.IndentedJSON(http.StatusOK, customers)
+	query, fieldErrors := parseListQuery(context.Request.URL.Query())
+
+	if len(fieldErrors) > 0 {
+		abortWithProblem(newInvalidQueryProblem(fieldErrors), context)
+		return
+	}
+
+	if getNegotiation(context).MediaType == ndjsonContentType {
+		streamCustomersAsNDJSON(query, context)
+		return
+	}
+
+	page, err := repository.List(context.Request.Context(), query)
+
+	if err != nil {
+		respondWithRepositoryError(err, context)
+		return
+	}
+
+	setPaginationLinks(page, context)
+	respond(http.StatusOK, newCustomerListResponse(page), context)
 }
 
+// searchCustomers responds with the customers whose name, surname or email
+// match the q query parameter, even partially or misspelled, from the best
+// match to the worst one.
+func searchCustomers(context *gin.Context) {
+	query, fieldErrors := parseSearchQuery(context.Request.URL.Query())
+
+	if len(fieldErrors) > 0 {
+		abortWithProblem(newInvalidQueryProblem(fieldErrors), context)
+		return
+	}
+
+	searcher, isSearchable := repository.(customerSearcher)
+
+	if !isSearchable {
+		notImplementedProblem := newStatusProblem(http.StatusNotImplemented)
+		notImplementedProblem.Detail = "Customers cannot be searched"
+		abortWithProblem(notImplementedProblem, context)
+		return
+	}
+
+	results, total, err := searcher.Search(context.Request.Context(), query)
+
+	if err != nil {
+		respondWithRepositoryError(err, context)
+		return
+	}
+
+	respond(http.StatusOK, searchResponse{Data: results, Total: total}, context)
+}
+
+// updateCustomer replaces the information of a customer. If the If-Match
+// header is sent, the customer is only updated if it has its ETag.
 func updateCustomer(context *gin.Context) {
 	id := context.Param("id")
 
 	isIdValid := validateId(id, context)
 
+	if !isIdValid || !checkIfMatchIsSent(context) {
+		return
+	}
+
+	storedCustomer, err := repository.Get(context.Request.Context(), id)
+
+	if err != nil {
+		respondWithRepositoryError(err, context)
+		return
+	}
+
+	conditions, isPreconditionMet := checkIfMatch(storedCustomer, context)
+
+	if !isPreconditionMet {
+		return
+	}
+
+	var newCustomer customer
+
+	if !bindRequestBody(&newCustomer, context) {
+		return
+	}
+
+	isUserInformationValid := verifyCustomerInformation(newCustomer, context)
+
+	if !isUserInformationValid {
+		return
+	}
+
+	updatedCustomer, err := repository.Update(context.Request.Context(), id, newCustomer, conditions...)
+
+	if err != nil {
+		respondWithRepositoryError(err, context)
+		return
+	}
+
+	setCustomerETag(updatedCustomer, context)
+	respond(http.StatusOK, updatedCustomer, context)
+}
+
+// patchCustomer changes some of the information of a customer with a JSON
+// Merge Patch or a JSON Patch, depending on the Content-Type. The patched
+// customer is validated as a whole, and its ID can't be changed. If-Match
+// works as in updateCustomer.
+func patchCustomer(context *gin.Context) {
+	id := context.Param("id")
+
+	isIdValid := validateId(id, context)
+
 	if !isIdValid {
 		return
 	}
 
-	customerInformation := searchCustomer(id)
+	context.Header("Accept-Patch", acceptedPatchContentTypes)
+
+	if !checkIfMatchIsSent(context) {
+		return
+	}
+
+	patch, err := io.ReadAll(context.Request.Body)
 
-	if customerInformation.ID != "" {
-		var newCustomer customer
+	if err != nil {
+		abortWithProblem(newInvalidBodyProblem(err), context)
+		return
+	}
 
-		// Call BindJSON to bind the received JSON to
-		// newCustomer.
-		if err := context.BindJSON(&newCustomer); err != nil {
+	for attempt := 1; ; attempt++ {
+		storedCustomer, err := repository.Get(context.Request.Context(), id)
+
+		if err != nil {
+			respondWithRepositoryError(err, context)
+			return
+		}
+
+		if _, isPreconditionMet := checkIfMatch(storedCustomer, context); !isPreconditionMet {
 			return
 		}
 
-		isUserInformationValid := verifyCustomerInformation(newCustomer, context)
+		patchedCustomer, patchProblem := applyCustomerPatch(storedCustomer, context.ContentType(), patch)
+
+		if patchProblem != nil {
+			abortWithProblem(patchProblem, context)
+			return
+		}
+
+		if patchedCustomer.ID != id {
+			abortWithProblem(newValidationProblem([]fieldError{
+				{"id", notAllowedFieldErrorCode, "ID cannot be changed"},
+			}), context)
+			return
+		}
+
+		isUserInformationValid := verifyCustomerInformation(patchedCustomer, context)
 
 		if !isUserInformationValid {
 			return
 		}
 
-		updateCustomerInformation(id, newCustomer)
+		// The patch was applied to storedCustomer, so it's only written if
+		// nobody changed the customer since it was read, even without
+		// If-Match. Otherwise the patch is applied again to the new version,
+		// unless the client asked for the one it had.
+		updatedCustomer, err := repository.Update(context.Request.Context(), id, patchedCustomer, ifVersion(storedCustomer.Version))
 
-		context.IndentedJSON(http.StatusOK, newCustomer)
-	} else {
-		context.IndentedJSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+		if errors.Is(err, ErrVersionMismatch) && context.GetHeader("If-Match") == "" {
+			if attempt < maxPatchAttempts {
+				continue
+			}
+
+			err = newProblem(http.StatusConflict, patchConflictProblemType, "Patch cannot be applied to the customer",
+				"The customer kept changing while the patch was applied")
+		}
+
+		if err != nil {
+			respondWithRepositoryError(err, context)
+			return
+		}
+
+		setCustomerETag(updatedCustomer, context)
+		respond(http.StatusOK, updatedCustomer, context)
+
+		return
 	}
 }
 
+// deleteCustomer removes a customer. If-Match works as in updateCustomer.
 func deleteCustomer(context *gin.Context) {
 	id := context.Param("id")
 
 	isIdValid := validateId(id, context)
 
-	if !isIdValid {
+	if !isIdValid || !checkIfMatchIsSent(context) {
 		return
 	}
 
-	customer := searchCustomer(id)
+	var conditions []writeCondition
+
+	if context.GetHeader("If-Match") != "" {
+		storedCustomer, err := repository.Get(context.Request.Context(), id)
 
-	if customer.ID != "" {
-		removeCustomer(id)
-		context.IndentedJSON(http.StatusOK, gin.H{"message": "Customer deleted successfuly"})
-	} else {
-		context.IndentedJSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+		if err != nil {
+			respondWithRepositoryError(err, context)
+			return
+		}
+
+		var isPreconditionMet bool
+
+		if conditions, isPreconditionMet = checkIfMatch(storedCustomer, context); !isPreconditionMet {
+			return
+		}
+	}
+
+	if err := repository.Delete(context.Request.Context(), id, conditions...); err != nil {
+		respondWithRepositoryError(err, context)
+		return
 	}
+
+	respond(http.StatusOK, gin.H{"message": "Customer deleted successfuly"}, context)
+}
+
+// respondWithRepositoryError translates an error returned by the
+// repository into the matching problem. Problems are responded as they are.
+func respondWithRepositoryError(err error, context *gin.Context) {
+	repositoryProblem := newRepositoryProblem(err)
+
+	if repositoryProblem.Status == http.StatusInternalServerError {
+		// Keep the cause of unexpected errors for the logs.
+		context.Error(err)
+	}
+
+	abortWithProblem(repositoryProblem, context)
 }
 
 //Used for testing porpuses
 func clearCustomers(context *gin.Context) {
-	customers = []customer{}
+	// An empty repository has nothing to index, so it's wrapped as main
+	// does without reading it.
+	repository = &searchableCustomerRepository{CustomerRepository: newInMemoryCustomerRepository(), index: newSearchIndex()}
 }
@@ -2,12 +2,14 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -105,6 +107,33 @@ func getMockedCustomersResponse() []gin.H {
 	return mockedCustomerInformation
 }
 
+// getMockedProblemResponse returns the body of the response expected for
+// p, decoded the same way the tests decode the responses.
+func getMockedProblemResponse(p *problem) gin.H {
+	jsonbytes, marshalError := json.Marshal(p)
+	if marshalError != nil {
+		panic(marshalError)
+	}
+
+	var response gin.H
+
+	if err := json.Unmarshal(jsonbytes, &response); err != nil {
+		panic(err)
+	}
+
+	return response
+}
+
+// getMockedValidationErrorResponse returns the body of the response
+// expected when the customer sent to instance is rejected because of
+// fieldErrors.
+func getMockedValidationErrorResponse(instance string, fieldErrors ...fieldError) gin.H {
+	validationProblem := newValidationProblem(fieldErrors)
+	validationProblem.Instance = instance
+
+	return getMockedProblemResponse(validationProblem)
+}
+
 func TestPostCustomer(t *testing.T) {
 	writer := httptest.NewRecorder()
 	context, _ := gin.CreateTestContext(writer)
@@ -138,6 +167,9 @@ func TestPostCustomer(t *testing.T) {
 }
 
 func TestPostCustomerWithEmptyId(t *testing.T) {
+	customerIdAssignment = clientIdAssignment
+	defer func() { customerIdAssignment = optionalIdAssignment }()
+
 	writer := httptest.NewRecorder()
 	context, _ := gin.CreateTestContext(writer)
 
@@ -172,7 +204,94 @@ func TestPostCustomerWithEmptyId(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, gin.H{"error": "ID cannot be null or empty"}, got)
+	assert.Equal(t, getMockedValidationErrorResponse("", fieldError{"id", requiredFieldErrorCode, "ID cannot be null or empty"}), got)
+}
+
+func TestPostCustomerWithoutIdGeneratesOne(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	customerIdGenerator = newSequenceIdGenerator(0)
+	router := setupRouter()
+
+	defer func() {
+		customerIdGenerator = newULIDIdGenerator()
+		clearCustomers(nil)
+	}()
+
+	mockedCustomer := getMockedCustomer()
+	mockedCustomer.ID = ""
+
+	for _, expectedId := range []string{"1", "2"} {
+		writer := sendRequestForTesting(router, "POST", "/customer", mockedCustomer)
+
+		assert.Equal(t, 201, writer.Code)
+		assert.Equal(t, "/customer/"+expectedId, writer.Header().Get("Location"))
+
+		var got gin.H
+
+		if err := json.Unmarshal(writer.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, expectedId, got["id"])
+	}
+}
+
+func TestPostCustomerWithoutIdSkipsTakenIds(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	customerIdGenerator = newSequenceIdGenerator(0)
+	router := setupRouter()
+
+	defer func() {
+		customerIdGenerator = newULIDIdGenerator()
+		clearCustomers(nil)
+	}()
+
+	mockedCustomer := getMockedCustomer()
+
+	for _, id := range []string{"1", "2"} {
+		mockedCustomer.ID = id
+		writer := sendRequestForTesting(router, "POST", "/customer", mockedCustomer)
+		assert.Equal(t, 201, writer.Code)
+	}
+
+	mockedCustomer.ID = ""
+	writer := sendRequestForTesting(router, "POST", "/customer", mockedCustomer)
+
+	assert.Equal(t, 201, writer.Code)
+	assert.Equal(t, "/customer/3", writer.Header().Get("Location"))
+}
+
+func TestPostCustomerWithIdWhenAssignedByServer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	customerIdAssignment = serverIdAssignment
+	router := setupRouter()
+
+	defer func() {
+		customerIdAssignment = optionalIdAssignment
+		clearCustomers(nil)
+	}()
+
+	writer := sendRequestForTesting(router, "POST", "/customer", getMockedCustomer())
+
+	assert.Equal(t, 400, writer.Code)
+
+	var got gin.H
+
+	if err := json.Unmarshal(writer.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, getMockedValidationErrorResponse("/customer", fieldError{"id", notAllowedFieldErrorCode, "ID is assigned by the server and must not be sent"}), got)
+
+	mockedCustomer := getMockedCustomer()
+	mockedCustomer.ID = ""
+	writer = sendRequestForTesting(router, "POST", "/customer", mockedCustomer)
+
+	assert.Equal(t, 201, writer.Code)
+	assert.Regexp(t, "^/customer/[0-9A-Z]{26}$", writer.Header().Get("Location"))
 }
 
 func TestPostCustomerWithEmptyName(t *testing.T) {
@@ -210,7 +329,7 @@ func TestPostCustomerWithEmptyName(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, gin.H{"error": "Name cannot be null or empty"}, got)
+	assert.Equal(t, getMockedValidationErrorResponse("", fieldError{"name", requiredFieldErrorCode, "Name cannot be null or empty"}), got)
 }
 
 func TestPostCustomerWithEmptySurname(t *testing.T) {
@@ -248,7 +367,7 @@ func TestPostCustomerWithEmptySurname(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, gin.H{"error": "Surname cannot be null or empty"}, got)
+	assert.Equal(t, getMockedValidationErrorResponse("", fieldError{"surname", requiredFieldErrorCode, "Surname cannot be null or empty"}), got)
 }
 
 func TestPostCustomerWithIncorrectEmail(t *testing.T) {
@@ -286,7 +405,7 @@ func TestPostCustomerWithIncorrectEmail(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, gin.H{"error": "Email is not valid"}, got)
+	assert.Equal(t, getMockedValidationErrorResponse("", fieldError{"email", invalidFieldErrorCode, "Email is not valid"}), got)
 }
 
 func TestPostCustomerWithEmptyEmail(t *testing.T) {
@@ -324,7 +443,7 @@ func TestPostCustomerWithEmptyEmail(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, gin.H{"error": "Email cannot be null or empty"}, got)
+	assert.Equal(t, getMockedValidationErrorResponse("", fieldError{"email", requiredFieldErrorCode, "Email cannot be null or empty"}), got)
 }
 
 func TestPostCustomerWithInvalidBirthdate(t *testing.T) {
@@ -362,7 +481,7 @@ func TestPostCustomerWithInvalidBirthdate(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, gin.H{"error": "Birthdate is not valid"}, got)
+	assert.Equal(t, getMockedValidationErrorResponse("", fieldError{"birthdate", invalidFieldErrorCode, "Birthdate is not valid"}), got)
 }
 
 func TestPostCustomerWithEmptyBirdthdate(t *testing.T) {
@@ -400,7 +519,7 @@ func TestPostCustomerWithEmptyBirdthdate(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, gin.H{"error": "Birthdate cannot be null or empty"}, got)
+	assert.Equal(t, getMockedValidationErrorResponse("", fieldError{"birthdate", requiredFieldErrorCode, "Birthdate cannot be null or empty"}), got)
 }
 
 func TestPostCustomerWithBirdthdateAfterToday(t *testing.T) {
@@ -418,7 +537,7 @@ func TestPostCustomerWithBirdthdateAfterToday(t *testing.T) {
 		Name:      "Augusto",
 		Surname:   "Giavedoni",
 		Email:     "augusto.giavedoni@gmail.com",
-		Birthdate: "2023-05-13",
+		Birthdate: time.Now().AddDate(1, 0, 0).Format("2006-01-02"),
 	})
 	if marshalError != nil {
 		panic(marshalError)
@@ -438,8 +557,75 @@ func TestPostCustomerWithBirdthdateAfterToday(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, gin.H{"error": "Birthdate cannot be after today"}, got)
+	assert.Equal(t, getMockedValidationErrorResponse("", fieldError{"birthdate", futureDateFieldErrorCode, "Birthdate cannot be after today"}), got)
 }
+func TestPostCustomerReturnsEveryValidationError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	customerIdAssignment = clientIdAssignment
+	router := setupRouter()
+
+	defer func() {
+		customerIdAssignment = optionalIdAssignment
+		clearCustomers(nil)
+	}()
+
+	writer := sendRequestForTesting(router, "POST", "/customer", customer{
+		ID:        "/",
+		Name:      "",
+		Surname:   "",
+		Email:     "a.a",
+		Birthdate: time.Now().AddDate(0, 0, 1).Format("2006-01-02"),
+	})
+
+	assert.Equal(t, 400, writer.Code)
+
+	var got gin.H
+
+	if err := json.Unmarshal(writer.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, getMockedValidationErrorResponse("/customer",
+		fieldError{"id", invalidFieldErrorCode, "ID is not valid"},
+		fieldError{"name", requiredFieldErrorCode, "Name cannot be null or empty"},
+		fieldError{"surname", requiredFieldErrorCode, "Surname cannot be null or empty"},
+		fieldError{"email", invalidFieldErrorCode, "Email is not valid"},
+		fieldError{"birthdate", futureDateFieldErrorCode, "Birthdate cannot be after today"},
+	), got)
+
+	customers, _ := listAllCustomers(context.Background(), repository)
+	assert.Empty(t, customers)
+}
+
+func TestUpdateCustomerReturnsEveryValidationError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	writer := sendRequestForTesting(router, "POST", "/customer", getMockedCustomer())
+	assert.Equal(t, 201, writer.Code)
+
+	writer = sendRequestForTesting(router, "PUT", "/customer/1", customer{ID: "1"})
+
+	assert.Equal(t, 400, writer.Code)
+
+	var got gin.H
+
+	if err := json.Unmarshal(writer.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, getMockedValidationErrorResponse("/customer/1",
+		fieldError{"name", requiredFieldErrorCode, "Name cannot be null or empty"},
+		fieldError{"surname", requiredFieldErrorCode, "Surname cannot be null or empty"},
+		fieldError{"email", requiredFieldErrorCode, "Email cannot be null or empty"},
+		fieldError{"birthdate", requiredFieldErrorCode, "Birthdate cannot be null or empty"},
+	), got)
+}
+
 func TestGetCustomerByIdSuccessfuly(t *testing.T) {
 	postCustomerForTesting(t)
 
@@ -506,7 +692,7 @@ func TestGetCustomerByIdWithNonExistentId(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, gin.H{"error": "Customer not found"}, got)
+	assert.Equal(t, getMockedProblemResponse(newProblem(404, customerNotFoundProblemType, "Customer not found", "")), got)
 	clearCustomers(context)
 }
 
@@ -541,7 +727,7 @@ func TestGetCustomerByIdWithWrongId(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, gin.H{"error": "ID is not valid"}, got)
+	assert.Equal(t, getMockedProblemResponse(newProblem(400, invalidIdProblemType, "ID is not valid", "")), got)
 	clearCustomers(context)
 }
 
@@ -576,7 +762,7 @@ func TestGetCustomerByIdWithEmptyId(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, gin.H{"error": "ID must not be empty"}, got)
+	assert.Equal(t, getMockedProblemResponse(newProblem(400, invalidIdProblemType, "ID must not be empty", "")), got)
 	clearCustomers(context)
 }
 
@@ -606,11 +792,21 @@ func TestGetCustomersSuccessfuly(t *testing.T) {
 	writer := httptest.NewRecorder()
 	context, _ := gin.CreateTestContext(writer)
 
+	context.Request = &http.Request{
+		URL:    &url.URL{},
+		Header: make(http.Header),
+		Method: "GET",
+	}
+
 	getCustomers(context)
 
 	assert.Equal(t, 200, writer.Code)
 
-	var got []gin.H
+	var got struct {
+		Data  []gin.H `json:"data"`
+		Next  *string `json:"next"`
+		Total int     `json:"total"`
+	}
 
 	err := json.Unmarshal(writer.Body.Bytes(), &got)
 
@@ -618,7 +814,9 @@ func TestGetCustomersSuccessfuly(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, getMockedCustomersResponse(), got)
+	assert.Equal(t, getMockedCustomersResponse(), got.Data)
+	assert.Nil(t, got.Next)
+	assert.Equal(t, 2, got.Total)
 	clearCustomers(context)
 }
 
@@ -727,7 +925,7 @@ func TestUpdateCustomerWithNonExistingId(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, gin.H{"error": "Customer not found"}, got)
+	assert.Equal(t, getMockedProblemResponse(newProblem(404, customerNotFoundProblemType, "Customer not found", "")), got)
 	clearCustomers(context)
 }
 
@@ -794,7 +992,7 @@ func TestDeleteCustomerWithNonExistentId(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, gin.H{"error": "Customer not found"}, got)
+	assert.Equal(t, getMockedProblemResponse(newProblem(404, customerNotFoundProblemType, "Customer not found", "")), got)
 }
 
 func TestDeleteCustomerWithInvalidId(t *testing.T) {
@@ -827,5 +1025,117 @@ func TestDeleteCustomerWithInvalidId(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, gin.H{"error": "ID is not valid"}, got)
+	assert.Equal(t, getMockedProblemResponse(newProblem(400, invalidIdProblemType, "ID is not valid", "")), got)
+}
+
+func TestDeleteCustomerByIdMatrix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		existingIds   []string
+		deletedIds    []string
+		expectedCodes []int
+		remainingIds  []string
+	}{
+		{
+			name:          "delete the last customer",
+			existingIds:   []string{"1", "2", "3"},
+			deletedIds:    []string{"3"},
+			expectedCodes: []int{200},
+			remainingIds:  []string{"1", "2"},
+		},
+		{
+			name:          "delete the first customer",
+			existingIds:   []string{"1", "2", "3"},
+			deletedIds:    []string{"1"},
+			expectedCodes: []int{200},
+			remainingIds:  []string{"2", "3"},
+		},
+		{
+			name:          "delete after others were removed",
+			existingIds:   []string{"1", "2", "3", "4", "5"},
+			deletedIds:    []string{"2", "1", "5"},
+			expectedCodes: []int{200, 200, 200},
+			remainingIds:  []string{"3", "4"},
+		},
+		{
+			name:          "non-sequential IDs",
+			existingIds:   []string{"13", "2", "0", "45"},
+			deletedIds:    []string{"45", "0"},
+			expectedCodes: []int{200, 200},
+			remainingIds:  []string{"13", "2"},
+		},
+		{
+			name:          "ID that is not a position of the list",
+			existingIds:   []string{"1"},
+			deletedIds:    []string{"5"},
+			expectedCodes: []int{404},
+			remainingIds:  []string{"1"},
+		},
+		{
+			name:          "delete the same customer twice",
+			existingIds:   []string{"1", "2"},
+			deletedIds:    []string{"2", "2"},
+			expectedCodes: []int{200, 404},
+			remainingIds:  []string{"1"},
+		},
+		{
+			name:          "delete from an empty list",
+			existingIds:   []string{},
+			deletedIds:    []string{"0"},
+			expectedCodes: []int{404},
+			remainingIds:  []string{},
+		},
+		{
+			name:          "non-numeric IDs",
+			existingIds:   []string{"abc", "7", "customer-9", "Z_1"},
+			deletedIds:    []string{"customer-9", "abc", "xyz"},
+			expectedCodes: []int{200, 200, 404},
+			remainingIds:  []string{"7", "Z_1"},
+		},
+		{
+			name:          "invalid ID",
+			existingIds:   []string{"1"},
+			deletedIds:    []string{"a%20b"},
+			expectedCodes: []int{400},
+			remainingIds:  []string{"1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repository = newInMemoryCustomerRepository()
+			router := setupRouter()
+
+			for _, id := range test.existingIds {
+				mockedCustomer := getMockedCustomer()
+				mockedCustomer.ID = id
+
+				writer := sendRequestForTesting(router, "POST", "/customer", mockedCustomer)
+				assert.Equal(t, 201, writer.Code)
+			}
+
+			for i, id := range test.deletedIds {
+				writer := sendRequestForTesting(router, "DELETE", "/customer/"+id, nil)
+				assert.Equal(t, test.expectedCodes[i], writer.Code, "deleting %q", id)
+			}
+
+			remainingCustomers, err := listAllCustomers(context.Background(), repository)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			remainingIds := []string{}
+
+			for _, remainingCustomer := range remainingCustomers {
+				remainingIds = append(remainingIds, remainingCustomer.ID)
+			}
+
+			assert.Equal(t, test.remainingIds, remainingIds)
+		})
+	}
+
+	clearCustomers(nil)
 }
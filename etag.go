@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requireIfMatch makes PUT, PATCH and DELETE of a customer fail unless they
+// send an If-Match header. It's replaced on startup by the configured
+// require_if_match.
+var requireIfMatch = false
+
+// formatCustomerETag returns the entity tag of a customer at version. The
+// repositories never repeat a version for an ID, not even after the
+// customer is deleted and created again, so a tag can't match a customer
+// it wasn't sent for.
+func formatCustomerETag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+func setCustomerETag(storedCustomer customer, context *gin.Context) {
+	context.Header("ETag", formatCustomerETag(storedCustomer.Version))
+}
+
+// matchesETag reports whether header, the list of entity tags of an
+// If-Match or If-None-Match header, has etag or is "*". The weak comparison
+// ignores the W/ prefix of the tags, while the strong one never matches
+// them (RFC 7232, section 2.3.2).
+func matchesETag(header string, etag string, isWeakComparison bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if isWeakComparison {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkIfNoneMatch responds with 304 Not Modified if the If-None-Match
+// header matches storedCustomer, so the client can keep using its copy.
+func checkIfNoneMatch(storedCustomer customer, context *gin.Context) bool {
+	header := context.GetHeader("If-None-Match")
+
+	if header == "" || !matchesETag(header, formatCustomerETag(storedCustomer.Version), true) {
+		return true
+	}
+
+	setCustomerETag(storedCustomer, context)
+	context.AbortWithStatus(http.StatusNotModified)
+
+	return false
+}
+
+// checkIfMatchIsSent responds with 428 Precondition Required if If-Match
+// is required but the request doesn't have it.
+func checkIfMatchIsSent(context *gin.Context) bool {
+	if !requireIfMatch || context.GetHeader("If-Match") != "" {
+		return true
+	}
+
+	abortWithProblem(newPreconditionRequiredProblem("If-Match header with the ETag of the customer is required"), context)
+
+	return false
+}
+
+// newPreconditionRequiredProblem reports a change of a customer that
+// didn't say which version it was meant for, when that's required.
+func newPreconditionRequiredProblem(detail string) *problem {
+	preconditionProblem := newStatusProblem(http.StatusPreconditionRequired)
+	preconditionProblem.Detail = detail
+
+	return preconditionProblem
+}
+
+// checkIfMatch evaluates the If-Match header against storedCustomer,
+// responding with 412 Precondition Failed if it doesn't match. Otherwise it
+// returns the conditions that make the repository reject the write if the
+// customer changes in the meantime.
+func checkIfMatch(storedCustomer customer, context *gin.Context) ([]writeCondition, bool) {
+	header := context.GetHeader("If-Match")
+
+	if header == "" {
+		return nil, true
+	}
+
+	if !matchesETag(header, formatCustomerETag(storedCustomer.Version), false) {
+		respondWithRepositoryError(ErrVersionMismatch, context)
+		return nil, false
+	}
+
+	return []writeCondition{ifVersion(storedCustomer.Version)}, true
+}
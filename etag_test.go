@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func sendConditionalRequestForTesting(router *gin.Engine, method string, path string, header string, value string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+
+	if method == "PATCH" {
+		request.Header.Set("Content-Type", mergePatchContentType)
+	}
+
+	request.Header.Set(header, value)
+
+	writer := httptest.NewRecorder()
+	router.ServeHTTP(writer, request)
+
+	return writer
+}
+
+func TestRepositoryWriteConditions(t *testing.T) {
+	for name, repository := range getRepositoriesForTesting(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			_, err := repository.Create(ctx, getMockedCustomer())
+			assert.NoError(t, err)
+
+			_, err = repository.Update(ctx, "1", getMockedUpdatedCustomerInformation(), ifVersion(2))
+			assert.ErrorIs(t, err, ErrVersionMismatch)
+
+			updated, err := repository.Update(ctx, "1", getMockedUpdatedCustomerInformation(), ifVersion(1))
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(2), updated.Version)
+
+			// The failed update didn't change the customer.
+			got, err := repository.Get(ctx, "1")
+			assert.NoError(t, err)
+			assert.Equal(t, withVersion(getMockedUpdatedCustomerInformation(), 2), got)
+
+			assert.ErrorIs(t, repository.Delete(ctx, "1", ifVersion(1)), ErrVersionMismatch)
+			assert.ErrorIs(t, repository.Delete(ctx, "2", ifVersion(1)), ErrNotFound)
+			assert.NoError(t, repository.Delete(ctx, "1", ifVersion(2)))
+		})
+	}
+}
+
+func TestRepositoryVersionsContinueAfterDeletion(t *testing.T) {
+	for name, repository := range getRepositoriesForTesting(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			_, err := repository.Create(ctx, getMockedCustomer())
+			assert.NoError(t, err)
+
+			_, err = repository.Update(ctx, "1", getMockedUpdatedCustomerInformation())
+			assert.NoError(t, err)
+			assert.NoError(t, repository.Delete(ctx, "1"))
+
+			// The versions of the deleted customer never match the new one.
+			created, err := repository.Create(ctx, getMockedCustomer())
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(3), created.Version)
+
+			got, err := repository.Get(ctx, "1")
+			assert.NoError(t, err)
+			assert.Equal(t, withVersion(getMockedCustomer(), 3), got)
+
+			assert.ErrorIs(t, repository.Delete(ctx, "1", ifVersion(1)), ErrVersionMismatch)
+
+			// Nothing is kept per deleted ID, so customers with other IDs
+			// also start after the highest deleted version.
+			otherCustomer := getMockedCustomer()
+			otherCustomer.ID = "2"
+			otherCustomer.Email = "other@gmail.com"
+
+			created, err = repository.Create(ctx, otherCustomer)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(3), created.Version)
+
+			assert.NoError(t, repository.Delete(ctx, "2"))
+
+			created, err = repository.Create(ctx, otherCustomer)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(4), created.Version)
+		})
+	}
+}
+
+func TestStaleETagDoesNotMatchCustomerCreatedAgain(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	writer := sendRequestForTesting(router, "POST", "/customer", getMockedCustomer())
+	staleETag := writer.Header().Get("ETag")
+
+	writer = sendRequestForTesting(router, "DELETE", "/customer/1", nil)
+	assert.Equal(t, 200, writer.Code)
+
+	writer = sendRequestForTesting(router, "POST", "/customer", getMockedCustomer())
+	assert.Equal(t, 201, writer.Code)
+	assert.NotEqual(t, staleETag, writer.Header().Get("ETag"))
+
+	writer = sendConditionalRequestForTesting(router, "DELETE", "/customer/1", "If-Match", staleETag, "")
+	assert.Equal(t, 412, writer.Code)
+}
+
+func TestCustomerETags(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	writer := sendRequestForTesting(router, "POST", "/customer", getMockedCustomer())
+	assert.Equal(t, 201, writer.Code)
+	assert.Equal(t, `"1"`, writer.Header().Get("ETag"))
+
+	writer = sendRequestForTesting(router, "GET", "/customer/1", nil)
+	assert.Equal(t, 200, writer.Code)
+	assert.Equal(t, `"1"`, writer.Header().Get("ETag"))
+
+	writer = sendRequestForTesting(router, "PUT", "/customer/1", getMockedUpdatedCustomerInformation())
+	assert.Equal(t, 200, writer.Code)
+	assert.Equal(t, `"2"`, writer.Header().Get("ETag"))
+
+	writer = sendConditionalRequestForTesting(router, "PATCH", "/customer/1", "If-Match", `"2"`, `{"name": "John"}`)
+	assert.Equal(t, 200, writer.Code)
+	assert.Equal(t, `"3"`, writer.Header().Get("ETag"))
+}
+
+func TestGetCustomerWithIfNoneMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	sendRequestForTesting(router, "POST", "/customer", getMockedCustomer())
+
+	for _, header := range []string{`"1"`, `W/"1"`, `"0", "1"`, "*"} {
+		writer := sendConditionalRequestForTesting(router, "GET", "/customer/1", "If-None-Match", header, "")
+
+		assert.Equal(t, 304, writer.Code, header)
+		assert.Empty(t, writer.Body.String(), header)
+		assert.Equal(t, `"1"`, writer.Header().Get("ETag"), header)
+	}
+
+	writer := sendConditionalRequestForTesting(router, "GET", "/customer/1", "If-None-Match", `"0"`, "")
+	assert.Equal(t, 200, writer.Code)
+}
+
+func TestChangeCustomerWithIfMatch(t *testing.T) {
+	tests := []struct {
+		method string
+		body   string
+	}{
+		{"PUT", `{"id": "1", "name": "John", "surname": "Wick", "email": "john.wick@gmail.com", "birthdate": "1964-09-02"}`},
+		{"PATCH", `{"name": "John"}`},
+		{"DELETE", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			repository = newInMemoryCustomerRepository()
+			router := setupRouter()
+
+			defer clearCustomers(nil)
+
+			sendRequestForTesting(router, "POST", "/customer", getMockedCustomer())
+
+			// Weak tags never match If-Match.
+			for _, header := range []string{`"2"`, `W/"1"`} {
+				writer := sendConditionalRequestForTesting(router, test.method, "/customer/1", "If-Match", header, test.body)
+				assert.Equal(t, 412, writer.Code, header)
+
+				expected := newRepositoryProblem(ErrVersionMismatch)
+				expected.Instance = "/customer/1"
+
+				assert.Equal(t, getMockedProblemResponse(expected), getProblemFromResponse(t, writer), header)
+			}
+
+			stored, err := repository.Get(context.Background(), "1")
+			assert.NoError(t, err)
+			assert.Equal(t, withVersion(getMockedCustomer(), 1), stored)
+
+			writer := sendConditionalRequestForTesting(router, test.method, "/customer/1", "If-Match", `"0", "1"`, test.body)
+			assert.Equal(t, 200, writer.Code)
+
+			writer = sendConditionalRequestForTesting(router, test.method, "/customer/2", "If-Match", "*", test.body)
+			assert.Equal(t, 404, writer.Code)
+		})
+	}
+}
+
+func TestChangeCustomerWhenIfMatchIsRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	requireIfMatch = true
+	router := setupRouter()
+
+	defer func() {
+		requireIfMatch = false
+		clearCustomers(nil)
+	}()
+
+	sendRequestForTesting(router, "POST", "/customer", getMockedCustomer())
+
+	for _, method := range []string{"PUT", "PATCH", "DELETE"} {
+		writer := sendRequestForTesting(router, method, "/customer/1", getMockedUpdatedCustomerInformation())
+		assert.Equal(t, 428, writer.Code, method)
+
+		got := getProblemFromResponse(t, writer)
+		assert.Equal(t, "Precondition Required", got["title"], method)
+	}
+
+	writer := sendConditionalRequestForTesting(router, "DELETE", "/customer/1", "If-Match", "*", "")
+	assert.Equal(t, 200, writer.Code)
+}
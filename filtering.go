@@ -0,0 +1,385 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// filterOperator is how a customerFilter compares a field with its value.
+type filterOperator string
+
+const (
+	equalOperator                   filterOperator = "eq"
+	caseInsensitiveEqualOperator    filterOperator = "ieq"
+	prefixOperator                  filterOperator = "prefix"
+	caseInsensitivePrefixOperator   filterOperator = "iprefix"
+	caseInsensitiveContainsOperator filterOperator = "icontains"
+	greaterThanOperator             filterOperator = "gt"
+	greaterThanOrEqualOperator      filterOperator = "gte"
+	lessThanOperator                filterOperator = "lt"
+	lessThanOrEqualOperator         filterOperator = "lte"
+)
+
+// isCaseInsensitive reports whether the operator ignores the case of the
+// values it compares.
+func (operator filterOperator) isCaseInsensitive() bool {
+	return operator == caseInsensitiveEqualOperator ||
+		operator == caseInsensitivePrefixOperator ||
+		operator == caseInsensitiveContainsOperator
+}
+
+const (
+	customerIdField        = "id"
+	customerBirthdateField = "birthdate"
+
+	// customerBirthdateLayout is the format of the birthdates.
+	customerBirthdateLayout = "2006-01-02"
+
+	// Query parameters of GET /customers that aren't filters.
+	listLimitParameter  = "limit"
+	listCursorParameter = "cursor"
+	listSortParameter   = "sort"
+)
+
+// filterOperatorsByField lists the fields customers can be filtered and
+// sorted by, with the operators each one supports.
+var filterOperatorsByField = map[string][]filterOperator{
+	customerIdField: {equalOperator, prefixOperator},
+	"name":          {equalOperator, caseInsensitiveEqualOperator, prefixOperator, caseInsensitivePrefixOperator, caseInsensitiveContainsOperator},
+	"surname":       {equalOperator, caseInsensitiveEqualOperator, prefixOperator, caseInsensitivePrefixOperator, caseInsensitiveContainsOperator},
+	"email":         {equalOperator, caseInsensitiveEqualOperator, prefixOperator, caseInsensitivePrefixOperator, caseInsensitiveContainsOperator},
+	customerBirthdateField: {equalOperator, prefixOperator, greaterThanOperator, greaterThanOrEqualOperator,
+		lessThanOperator, lessThanOrEqualOperator},
+}
+
+// customerFilter keeps the customers whose Field compares to Value as
+// Operator says. Case insensitive operators expect Value to be lowercase.
+type customerFilter struct {
+	Field    string
+	Operator filterOperator
+	Value    string
+}
+
+// matches reports whether storedCustomer passes the filter.
+func (filter customerFilter) matches(storedCustomer customer) bool {
+	fieldValue := getCustomerField(storedCustomer, filter.Field)
+
+	switch filter.Operator {
+	case equalOperator:
+		return fieldValue == filter.Value
+	case caseInsensitiveEqualOperator:
+		return strings.ToLower(fieldValue) == filter.Value
+	case prefixOperator:
+		return strings.HasPrefix(fieldValue, filter.Value)
+	case caseInsensitivePrefixOperator:
+		return strings.HasPrefix(strings.ToLower(fieldValue), filter.Value)
+	case caseInsensitiveContainsOperator:
+		return strings.Contains(strings.ToLower(fieldValue), filter.Value)
+	case greaterThanOperator:
+		return fieldValue > filter.Value
+	case greaterThanOrEqualOperator:
+		return fieldValue >= filter.Value
+	case lessThanOperator:
+		return fieldValue < filter.Value
+	case lessThanOrEqualOperator:
+		return fieldValue <= filter.Value
+	default:
+		return false
+	}
+}
+
+// sortKey orders customers by one of their fields.
+type sortKey struct {
+	Field      string
+	Descending bool
+}
+
+// normalizeSort returns the keys customers are actually ordered by: the
+// requested ones followed by the ID, so the order is always the same even
+// if several customers share the sorted values.
+func normalizeSort(sortKeys []sortKey) []sortKey {
+	normalizedKeys := []sortKey{}
+
+	for _, key := range sortKeys {
+		normalizedKeys = append(normalizedKeys, key)
+
+		// IDs are unique, so nothing after them changes the order.
+		if key.Field == customerIdField {
+			return normalizedKeys
+		}
+	}
+
+	return append(normalizedKeys, sortKey{Field: customerIdField})
+}
+
+// formatSort returns sortKeys written as the sort query parameter.
+func formatSort(sortKeys []sortKey) string {
+	fields := []string{}
+
+	for _, key := range sortKeys {
+		if key.Descending {
+			fields = append(fields, "-"+key.Field)
+		} else {
+			fields = append(fields, key.Field)
+		}
+	}
+
+	return strings.Join(fields, ",")
+}
+
+// compareCustomerToPosition returns a negative number if storedCustomer
+// comes before the position made of values in the order given by sortKeys,
+// zero if it's at the position and a positive number if it comes after.
+func compareCustomerToPosition(storedCustomer customer, sortKeys []sortKey, values []string) int {
+	for i, key := range sortKeys {
+		if comparison := compareSortValue(key, getCustomerField(storedCustomer, key.Field), values[i]); comparison != 0 {
+			return comparison
+		}
+	}
+
+	return 0
+}
+
+// compareSortValues is compareCustomerToPosition for a customer whose
+// values were already read with getSortValues.
+func compareSortValues(sortKeys []sortKey, values []string, positionValues []string) int {
+	for i, key := range sortKeys {
+		if comparison := compareSortValue(key, values[i], positionValues[i]); comparison != 0 {
+			return comparison
+		}
+	}
+
+	return 0
+}
+
+func compareSortValue(key sortKey, value string, positionValue string) int {
+	comparison := strings.Compare(value, positionValue)
+
+	if key.Descending {
+		return -comparison
+	}
+
+	return comparison
+}
+
+// getSortValues returns the values of storedCustomer for each of sortKeys,
+// which is what a cursor keeps to find the next page.
+func getSortValues(storedCustomer customer, sortKeys []sortKey) []string {
+	values := make([]string, len(sortKeys))
+
+	for i, key := range sortKeys {
+		values[i] = getCustomerField(storedCustomer, key.Field)
+	}
+
+	return values
+}
+
+// selectSortedCustomers returns the first limit customers after the
+// position of positionValues, or from the start if it's nil, in the order
+// given by sortKeys, which must be normalized, and whether there are more
+// after them. The values of each customer are read once, and only the
+// selected customers are kept and sorted, so each page takes O(n log
+// limit) instead of sorting every customer again.
+func selectSortedCustomers(customers []customer, sortKeys []sortKey, positionValues []string, limit int) ([]customer, bool) {
+	selected := &sortedCustomerHeap{sortKeys: sortKeys}
+	hasMore := false
+
+	for _, storedCustomer := range customers {
+		values := getSortValues(storedCustomer, sortKeys)
+
+		if positionValues != nil && compareSortValues(sortKeys, values, positionValues) <= 0 {
+			continue
+		}
+
+		switch {
+		case selected.Len() < limit:
+			heap.Push(selected, sortedCustomer{storedCustomer, values})
+		case compareSortValues(sortKeys, values, selected.customers[0].values) < 0:
+			selected.customers[0] = sortedCustomer{storedCustomer, values}
+			heap.Fix(selected, 0)
+			hasMore = true
+		default:
+			hasMore = true
+		}
+	}
+
+	// Popping the heap returns the last customer first.
+	page := make([]customer, selected.Len())
+
+	for i := len(page) - 1; i >= 0; i-- {
+		page[i] = heap.Pop(selected).(sortedCustomer).customer
+	}
+
+	return page, hasMore
+}
+
+// sortedCustomer is a customer with its values for the sort keys.
+type sortedCustomer struct {
+	customer customer
+	values   []string
+}
+
+// sortedCustomerHeap keeps the customers with the last one by sortKeys
+// first, so it's the one replaced when a customer that comes before it is
+// found.
+type sortedCustomerHeap struct {
+	sortKeys  []sortKey
+	customers []sortedCustomer
+}
+
+func (h *sortedCustomerHeap) Len() int {
+	return len(h.customers)
+}
+
+func (h *sortedCustomerHeap) Less(i int, j int) bool {
+	return compareSortValues(h.sortKeys, h.customers[i].values, h.customers[j].values) > 0
+}
+
+func (h *sortedCustomerHeap) Swap(i int, j int) {
+	h.customers[i], h.customers[j] = h.customers[j], h.customers[i]
+}
+
+func (h *sortedCustomerHeap) Push(item interface{}) {
+	h.customers = append(h.customers, item.(sortedCustomer))
+}
+
+func (h *sortedCustomerHeap) Pop() interface{} {
+	last := h.customers[len(h.customers)-1]
+	h.customers = h.customers[:len(h.customers)-1]
+
+	return last
+}
+
+func getCustomerField(storedCustomer customer, field string) string {
+	switch field {
+	case customerIdField:
+		return storedCustomer.ID
+	case "name":
+		return storedCustomer.Name
+	case "surname":
+		return storedCustomer.Surname
+	case "email":
+		return storedCustomer.Email
+	case customerBirthdateField:
+		return storedCustomer.Birthdate
+	default:
+		panic(fmt.Sprintf("unknown customer field %q", field))
+	}
+}
+
+// parseFilters reads the filters from the query parameters that aren't
+// limit, cursor or sort. A filter is written as field=value, which means
+// the field must be equal to the value, or field[operator]=value.
+func parseFilters(parameters url.Values) ([]customerFilter, []fieldError) {
+	filters := []customerFilter{}
+	fieldErrors := []fieldError{}
+
+	// Go through the parameters in order, so errors are always reported
+	// the same way.
+	names := []string{}
+
+	for name := range parameters {
+		if name != listLimitParameter && name != listCursorParameter && name != listSortParameter {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		field, operator := name, equalOperator
+
+		if index := strings.Index(name, "["); index != -1 && strings.HasSuffix(name, "]") {
+			field, operator = name[:index], filterOperator(name[index+1:len(name)-1])
+		}
+
+		operators, isFieldKnown := filterOperatorsByField[field]
+
+		if !isFieldKnown {
+			fieldErrors = append(fieldErrors, fieldError{name, invalidFieldErrorCode, "Unknown query parameter"})
+			continue
+		}
+
+		if !containsFilterOperator(operators, operator) {
+			fieldErrors = append(fieldErrors, fieldError{name, invalidFieldErrorCode,
+				fmt.Sprintf("Operator %q is not supported by %s", operator, field)})
+			continue
+		}
+
+		for _, value := range parameters[name] {
+			if fieldError := validateFilterValue(name, field, operator, value); fieldError != nil {
+				fieldErrors = append(fieldErrors, *fieldError)
+				continue
+			}
+
+			if operator.isCaseInsensitive() {
+				value = strings.ToLower(value)
+			}
+
+			filters = append(filters, customerFilter{Field: field, Operator: operator, Value: value})
+		}
+	}
+
+	return filters, fieldErrors
+}
+
+func validateFilterValue(name string, field string, operator filterOperator, value string) *fieldError {
+	if value == "" {
+		return &fieldError{name, requiredFieldErrorCode, "Filter value cannot be empty"}
+	}
+
+	// Prefixes of birthdates, like a year, are not dates.
+	if field == customerBirthdateField && operator != prefixOperator {
+		if _, err := time.Parse(customerBirthdateLayout, value); err != nil {
+			return &fieldError{name, invalidFieldErrorCode, "Birthdate must have the YYYY-MM-DD format"}
+		}
+	}
+
+	return nil
+}
+
+// parseSort reads the sort query parameter: a comma separated list of
+// fields, each one preceded by "-" to sort in descending order.
+func parseSort(parameters url.Values) ([]sortKey, []fieldError) {
+	sortKeys := []sortKey{}
+	sortParameter := parameters.Get(listSortParameter)
+
+	if sortParameter == "" {
+		return sortKeys, nil
+	}
+
+	sortedFields := map[string]bool{}
+
+	for _, field := range strings.Split(sortParameter, ",") {
+		key := sortKey{Field: strings.TrimPrefix(field, "-"), Descending: strings.HasPrefix(field, "-")}
+
+		if _, isFieldKnown := filterOperatorsByField[key.Field]; !isFieldKnown {
+			return nil, []fieldError{{listSortParameter, invalidFieldErrorCode,
+				fmt.Sprintf("Customers cannot be sorted by %q", key.Field)}}
+		}
+
+		if sortedFields[key.Field] {
+			return nil, []fieldError{{listSortParameter, invalidFieldErrorCode,
+				fmt.Sprintf("Customers are already sorted by %q", key.Field)}}
+		}
+
+		sortedFields[key.Field] = true
+		sortKeys = append(sortKeys, key)
+	}
+
+	return sortKeys, nil
+}
+
+func containsFilterOperator(operators []filterOperator, operator filterOperator) bool {
+	for _, supportedOperator := range operators {
+		if supportedOperator == operator {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func getMockedCustomersForFiltering() []customer {
+	return []customer{
+		{ID: "1", Name: "Augusto", Surname: "Giavedoni", Email: "augusto.giavedoni@gmail.com", Birthdate: "2000-02-20"},
+		{ID: "2", Name: "John", Surname: "Wick", Email: "john.wick@gmail.com", Birthdate: "1964-09-02"},
+		{ID: "3", Name: "Helen", Surname: "Wick", Email: "Helen.Wick@Continental.com", Birthdate: "1970-05-13"},
+		{ID: "4", Name: "Ángela", Surname: "Ñúñez", Email: "angela@example.com", Birthdate: "1990-01-01"},
+		{ID: "5", Name: "Winston", Surname: "Scott", Email: "winston@continental.com", Birthdate: "1990-12-31"},
+	}
+}
+
+func TestRepositoryListWithFilters(t *testing.T) {
+	tests := []struct {
+		name        string
+		filters     []customerFilter
+		expectedIds []string
+	}{
+		{"equal", []customerFilter{{"surname", equalOperator, "Wick"}}, []string{"2", "3"}},
+		{"equal is case sensitive", []customerFilter{{"surname", equalOperator, "wick"}}, []string{}},
+		{"case insensitive equal", []customerFilter{{"surname", caseInsensitiveEqualOperator, "wick"}}, []string{"2", "3"}},
+		{"case insensitive equal with accents", []customerFilter{{"surname", caseInsensitiveEqualOperator, "ñúñez"}}, []string{"4"}},
+		{"prefix", []customerFilter{{"name", prefixOperator, "Jo"}}, []string{"2"}},
+		{"prefix with accents", []customerFilter{{"name", prefixOperator, "Án"}}, []string{"4"}},
+		{"prefix with LIKE wildcards", []customerFilter{{"email", prefixOperator, "%"}}, []string{}},
+		{"case insensitive prefix", []customerFilter{{"email", caseInsensitivePrefixOperator, "helen."}}, []string{"3"}},
+		{"case insensitive contains", []customerFilter{{"email", caseInsensitiveContainsOperator, "continental"}}, []string{"3", "5"}},
+		{"birthdate range", []customerFilter{
+			{"birthdate", greaterThanOrEqualOperator, "1970-05-13"},
+			{"birthdate", lessThanOperator, "1990-12-31"},
+		}, []string{"3", "4"}},
+		{"birthdate after", []customerFilter{{"birthdate", greaterThanOperator, "1990-01-01"}}, []string{"1", "5"}},
+		{"birthdate before or on", []customerFilter{{"birthdate", lessThanOrEqualOperator, "1970-05-13"}}, []string{"2", "3"}},
+		{"birthdate year", []customerFilter{{"birthdate", prefixOperator, "1990"}}, []string{"4", "5"}},
+		{"several fields", []customerFilter{
+			{"surname", equalOperator, "Wick"},
+			{"name", caseInsensitivePrefixOperator, "h"},
+		}, []string{"3"}},
+	}
+
+	for name, repository := range getRepositoriesForTesting(t) {
+		ctx := context.Background()
+
+		for _, mockedCustomer := range getMockedCustomersForFiltering() {
+			_, err := repository.Create(ctx, mockedCustomer)
+			assert.NoError(t, err)
+		}
+
+		for _, test := range tests {
+			t.Run(name+"/"+test.name, func(t *testing.T) {
+				page, err := repository.List(ctx, listQuery{Limit: 10, Filters: test.filters})
+
+				assert.NoError(t, err)
+				assert.Equal(t, test.expectedIds, getCustomerIds(page.Customers))
+				assert.Equal(t, len(test.expectedIds), page.Total)
+			})
+		}
+	}
+}
+
+func TestRepositoryListWithSort(t *testing.T) {
+	tests := []struct {
+		name        string
+		sort        []sortKey
+		expectedIds []string
+	}{
+		{"by surname, ties by ID", []sortKey{{"surname", false}}, []string{"1", "5", "2", "3", "4"}},
+		{"by surname descending, ties by ID", []sortKey{{"surname", true}}, []string{"4", "2", "3", "5", "1"}},
+		{"by surname and birthdate descending", []sortKey{{"surname", false}, {"birthdate", true}}, []string{"1", "5", "3", "2", "4"}},
+		{"by ID descending", []sortKey{{"id", true}}, []string{"5", "4", "3", "2", "1"}},
+		{"by birthdate", []sortKey{{"birthdate", false}}, []string{"2", "3", "4", "5", "1"}},
+	}
+
+	for name, repository := range getRepositoriesForTesting(t) {
+		ctx := context.Background()
+
+		for _, mockedCustomer := range getMockedCustomersForFiltering() {
+			_, err := repository.Create(ctx, mockedCustomer)
+			assert.NoError(t, err)
+		}
+
+		for _, test := range tests {
+			t.Run(name+"/"+test.name, func(t *testing.T) {
+				// Walk the pages two customers at a time, so the cursors
+				// are tested with every order.
+				ids := []string{}
+				query := listQuery{Limit: 2, Sort: test.sort}
+
+				for {
+					page, err := repository.List(ctx, query)
+
+					if !assert.NoError(t, err) {
+						return
+					}
+
+					ids = append(ids, getCustomerIds(page.Customers)...)
+
+					if page.Next == "" {
+						break
+					}
+
+					query.Cursor = page.Next
+				}
+
+				assert.Equal(t, test.expectedIds, ids)
+			})
+		}
+	}
+}
+
+func TestRepositoryListWithCursorFromAnotherSort(t *testing.T) {
+	for name, repository := range getRepositoriesForTesting(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			for _, mockedCustomer := range getMockedCustomersForFiltering() {
+				_, err := repository.Create(ctx, mockedCustomer)
+				assert.NoError(t, err)
+			}
+
+			page, err := repository.List(ctx, listQuery{Limit: 2, Sort: []sortKey{{"surname", false}}})
+			assert.NoError(t, err)
+
+			_, err = repository.List(ctx, listQuery{Limit: 2, Cursor: page.Next})
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+		})
+	}
+}
+
+func TestParseFiltersAndSort(t *testing.T) {
+	parameters, _ := url.ParseQuery("surname=Wick&name[ieq]=HELEN&birthdate[gte]=1970-01-01&birthdate[prefix]=19&limit=5&sort=surname,-birthdate")
+
+	filters, fieldErrors := parseFilters(parameters)
+
+	assert.Empty(t, fieldErrors)
+	assert.Equal(t, []customerFilter{
+		{"birthdate", greaterThanOrEqualOperator, "1970-01-01"},
+		{"birthdate", prefixOperator, "19"},
+		{"name", caseInsensitiveEqualOperator, "helen"},
+		{"surname", equalOperator, "Wick"},
+	}, filters)
+
+	sortKeys, fieldErrors := parseSort(parameters)
+
+	assert.Empty(t, fieldErrors)
+	assert.Equal(t, []sortKey{{"surname", false}, {"birthdate", true}}, sortKeys)
+}
+
+func TestParseFiltersAndSortWithErrors(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected []fieldError
+	}{
+		{"nickname=John", []fieldError{{"nickname", invalidFieldErrorCode, "Unknown query parameter"}}},
+		{"name[gt]=John", []fieldError{{"name[gt]", invalidFieldErrorCode, `Operator "gt" is not supported by name`}}},
+		{"birthdate[gt]=1990", []fieldError{{"birthdate[gt]", invalidFieldErrorCode, "Birthdate must have the YYYY-MM-DD format"}}},
+		{"surname=", []fieldError{{"surname", requiredFieldErrorCode, "Filter value cannot be empty"}}},
+		{"sort=age", []fieldError{{"sort", invalidFieldErrorCode, `Customers cannot be sorted by "age"`}}},
+		{"sort=name,-name", []fieldError{{"sort", invalidFieldErrorCode, `Customers are already sorted by "name"`}}},
+	}
+
+	for _, test := range tests {
+		parameters, _ := url.ParseQuery(test.query)
+
+		_, fieldErrors := parseListQuery(parameters)
+
+		assert.Equal(t, test.expected, fieldErrors, test.query)
+	}
+}
+
+func TestGetCustomersWithFiltersAndSort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	for _, mockedCustomer := range getMockedCustomersForFiltering() {
+		writer := sendRequestForTesting(router, "POST", "/customer", mockedCustomer)
+		assert.Equal(t, 201, writer.Code)
+	}
+
+	writer := sendRequestForTesting(router, "GET", "/customers?birthdate[gte]=1965-01-01&sort=-email&limit=2", nil)
+	assert.Equal(t, 200, writer.Code)
+
+	got := getCustomerListFromResponse(t, writer.Body.Bytes())
+
+	assert.Equal(t, []string{"5", "1"}, getCustomerIds(got.Data))
+	assert.Equal(t, 4, got.Total)
+
+	// The links keep the filters and the sort.
+	assert.Contains(t, writer.Header().Get("Link"),
+		`</customers?birthdate%5Bgte%5D=1965-01-01&cursor=`+*got.Next+`&limit=2&sort=-email>; rel="next"`)
+
+	writer = sendRequestForTesting(router, "GET", "/customers?birthdate[gte]=1965-01-01&sort=-email&limit=2&cursor="+*got.Next, nil)
+	got = getCustomerListFromResponse(t, writer.Body.Bytes())
+
+	assert.Equal(t, []string{"4", "3"}, getCustomerIds(got.Data))
+	assert.Nil(t, got.Next)
+
+	// A cursor can't be used with a different sort.
+	writer = sendRequestForTesting(router, "GET", "/customers?sort=-email&limit=2", nil)
+	got = getCustomerListFromResponse(t, writer.Body.Bytes())
+
+	writer = sendRequestForTesting(router, "GET", "/customers?sort=email&cursor="+*got.Next, nil)
+	assert.Equal(t, 400, writer.Code)
+}
+
+func TestSelectSortedCustomersWalksEveryPage(t *testing.T) {
+	customers := []customer{}
+
+	// Several customers share each name, so the ID breaks the ties.
+	for i := 0; i < 50; i++ {
+		customers = append(customers, customer{ID: fmt.Sprintf("%02d", (i*7)%50), Name: fmt.Sprintf("name-%d", i%6)})
+	}
+
+	sortKeys := normalizeSort([]sortKey{{Field: "name", Descending: true}})
+	want := append([]customer{}, customers...)
+	sort.Slice(want, func(i int, j int) bool {
+		return compareCustomerToPosition(want[i], sortKeys, getSortValues(want[j], sortKeys)) < 0
+	})
+
+	got := []customer{}
+	var positionValues []string
+
+	for {
+		page, hasMore := selectSortedCustomers(customers, sortKeys, positionValues, 7)
+		got = append(got, page...)
+
+		if !hasMore {
+			break
+		}
+
+		assert.Len(t, page, 7)
+		positionValues = getSortValues(page[len(page)-1], sortKeys)
+	}
+
+	assert.Equal(t, want, got)
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// graphqlResponseContentType is the media type of GraphQL responses
+// defined by the GraphQL over HTTP specification. Clients that don't ask
+// for it get application/json.
+const graphqlResponseContentType = "application/graphql-response+json"
+
+//go:embed schema.graphql
+var graphqlSchemaDefinition string
+
+// customerSchema resolves the GraphQL queries and mutations with the same
+// repository and validations as the rest of the API.
+var customerSchema = graphql.MustParseSchema(graphqlSchemaDefinition, &graphqlResolver{})
+
+// graphqlRequest is the body of POST /graphql.
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// postGraphql runs the query or mutation of the request body. Errors of
+// the fields are returned along with the data, as GraphQL does.
+func postGraphql(context *gin.Context) {
+	var request graphqlRequest
+
+	if err := context.ShouldBindJSON(&request); err != nil {
+		abortWithProblem(newInvalidBodyProblem(err), context)
+		return
+	}
+
+	if request.Query == "" {
+		abortWithProblem(newInvalidBodyProblem(fmt.Errorf("the query is required")), context)
+		return
+	}
+
+	response := customerSchema.Exec(context.Request.Context(), request.Query, request.OperationName, request.Variables)
+	result := getNegotiation(context)
+	status := http.StatusOK
+
+	// Requests that couldn't be run, like invalid queries, have no data.
+	// Only clients that know the newer media type expect a 400 code for
+	// them.
+	if result.MediaType == graphqlResponseContentType && response.Data == nil {
+		status = http.StatusBadRequest
+	}
+
+	context.Header("Content-Type", result.MediaType+"; charset=utf-8")
+	context.Render(status, bodyFormats[0].Render(response, result.IsPretty))
+}
+
+// getGraphiql serves GraphiQL, an editor for the queries of /graphql. It's
+// only registered in debug mode.
+func getGraphiql(context *gin.Context) {
+	context.Data(http.StatusOK, "text/html; charset=utf-8", []byte(graphiqlPage))
+}
+
+// graphqlResolver resolves the fields of Query and Mutation.
+type graphqlResolver struct{}
+
+func (graphqlResolver) Customer(ctx context.Context, args struct{ ID graphql.ID }) (*customerResolver, error) {
+	if err := validateGraphqlCustomerId(args.ID); err != nil {
+		return nil, err
+	}
+
+	storedCustomer, err := repository.Get(ctx, string(args.ID))
+
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, newGraphqlError(err)
+	}
+
+	return &customerResolver{storedCustomer}, nil
+}
+
+type customersArguments struct {
+	Filter *customerFilterInput
+	Sort   *string
+	First  *int32
+	After  *string
+}
+
+// Customers reads the arguments as the query parameters of GET /customers,
+// so they're validated the same way.
+func (graphqlResolver) Customers(ctx context.Context, args customersArguments) (*customerConnectionResolver, error) {
+	parameters := url.Values{}
+
+	if args.Filter != nil {
+		args.Filter.addTo(parameters)
+	}
+
+	if args.Sort != nil {
+		parameters.Set(listSortParameter, *args.Sort)
+	}
+
+	if args.First != nil {
+		parameters.Set(listLimitParameter, strconv.Itoa(int(*args.First)))
+	}
+
+	if args.After != nil {
+		parameters.Set(listCursorParameter, *args.After)
+	}
+
+	query, fieldErrors := parseListQuery(parameters)
+
+	if len(fieldErrors) > 0 {
+		// Name the arguments as they're named in GraphQL.
+		for i, fieldError := range fieldErrors {
+			switch fieldError.Field {
+			case listLimitParameter:
+				fieldErrors[i].Field = "first"
+			case listCursorParameter:
+				fieldErrors[i].Field = "after"
+			}
+		}
+
+		return nil, newGraphqlError(newInvalidQueryProblem(fieldErrors))
+	}
+
+	page, err := repository.List(ctx, query)
+
+	if err != nil {
+		return nil, newGraphqlError(err)
+	}
+
+	return &customerConnectionResolver{page: page, query: query}, nil
+}
+
+type customerInput struct {
+	ID        *graphql.ID
+	Name      string
+	Surname   string
+	Email     string
+	Birthdate string
+}
+
+func (input customerInput) toCustomer() customer {
+	newCustomer := customer{Name: input.Name, Surname: input.Surname, Email: input.Email, Birthdate: input.Birthdate}
+
+	if input.ID != nil {
+		newCustomer.ID = string(*input.ID)
+	}
+
+	return newCustomer
+}
+
+func (graphqlResolver) CreateCustomer(ctx context.Context, args struct{ Input customerInput }) (*customerResolver, error) {
+	createdCustomer, err := createCustomer(ctx, repository, args.Input.toCustomer())
+
+	if err != nil {
+		return nil, newGraphqlError(err)
+	}
+
+	return &customerResolver{createdCustomer}, nil
+}
+
+type updateCustomerArguments struct {
+	ID              graphql.ID
+	Input           customerInput
+	ExpectedVersion *int32
+}
+
+func (graphqlResolver) UpdateCustomer(ctx context.Context, args updateCustomerArguments) (*customerResolver, error) {
+	if err := validateGraphqlCustomerId(args.ID); err != nil {
+		return nil, err
+	}
+
+	newCustomer := args.Input.toCustomer()
+	newCustomer.ID = string(args.ID)
+
+	if fieldErrors := validateCustomer(newCustomer); len(fieldErrors) > 0 {
+		return nil, newGraphqlError(newValidationProblem(fieldErrors))
+	}
+
+	expectedVersion, err := getGraphqlExpectedVersion(args.ExpectedVersion)
+
+	if err != nil {
+		return nil, err
+	}
+
+	updatedCustomer, err := repository.Update(ctx, newCustomer.ID, newCustomer, expectVersion(expectedVersion)...)
+
+	if err != nil {
+		return nil, newGraphqlError(err)
+	}
+
+	return &customerResolver{updatedCustomer}, nil
+}
+
+type deleteCustomerArguments struct {
+	ID              graphql.ID
+	ExpectedVersion *int32
+}
+
+func (graphqlResolver) DeleteCustomer(ctx context.Context, args deleteCustomerArguments) (graphql.ID, error) {
+	if err := validateGraphqlCustomerId(args.ID); err != nil {
+		return "", err
+	}
+
+	expectedVersion, err := getGraphqlExpectedVersion(args.ExpectedVersion)
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := repository.Delete(ctx, string(args.ID), expectVersion(expectedVersion)...); err != nil {
+		return "", newGraphqlError(err)
+	}
+
+	return args.ID, nil
+}
+
+// getGraphqlExpectedVersion returns the expectedVersion of a change,
+// where 0 means any version, as in expectVersion. It's the GraphQL
+// counterpart of checkIfMatch: an expectedVersion is required when
+// If-Match is, and it must be a version a customer can have.
+func getGraphqlExpectedVersion(version *int32) (uint64, error) {
+	if version == nil {
+		if requireIfMatch {
+			return 0, newGraphqlError(newPreconditionRequiredProblem("The expectedVersion of the customer is required"))
+		}
+
+		return 0, nil
+	}
+
+	if *version < 1 {
+		return 0, newGraphqlError(newValidationProblem([]fieldError{
+			{"expectedVersion", invalidFieldErrorCode, "Expected version must be a positive integer"}}))
+	}
+
+	return uint64(*version), nil
+}
+
+func validateGraphqlCustomerId(id graphql.ID) error {
+	if fieldError := validateCustomerId(string(id)); fieldError != nil {
+		return newGraphqlError(newProblem(http.StatusBadRequest, invalidIdProblemType, fieldError.Message, ""))
+	}
+
+	return nil
+}
+
+type customerResolver struct {
+	customer customer
+}
+
+func (resolver *customerResolver) ID() graphql.ID {
+	return graphql.ID(resolver.customer.ID)
+}
+
+func (resolver *customerResolver) Name() string {
+	return resolver.customer.Name
+}
+
+func (resolver *customerResolver) Surname() string {
+	return resolver.customer.Surname
+}
+
+func (resolver *customerResolver) Email() string {
+	return resolver.customer.Email
+}
+
+func (resolver *customerResolver) Birthdate() string {
+	return resolver.customer.Birthdate
+}
+
+func (resolver *customerResolver) Version() int32 {
+	return int32(resolver.customer.Version)
+}
+
+// customerConnectionResolver resolves a page of customers as a Relay
+// connection, where each customer has the cursor of the page after it.
+type customerConnectionResolver struct {
+	page  customerPage
+	query listQuery
+}
+
+func (resolver *customerConnectionResolver) Edges() []*customerEdgeResolver {
+	edges := []*customerEdgeResolver{}
+
+	for _, pageCustomer := range resolver.page.Customers {
+		edges = append(edges, &customerEdgeResolver{cursor: resolver.query.encodeCursor(pageCustomer), node: pageCustomer})
+	}
+
+	return edges
+}
+
+func (resolver *customerConnectionResolver) Nodes() []*customerResolver {
+	nodes := []*customerResolver{}
+
+	for _, pageCustomer := range resolver.page.Customers {
+		nodes = append(nodes, &customerResolver{pageCustomer})
+	}
+
+	return nodes
+}
+
+func (resolver *customerConnectionResolver) PageInfo() *pageInfoResolver {
+	return &pageInfoResolver{resolver.page}
+}
+
+func (resolver *customerConnectionResolver) TotalCount() int32 {
+	return int32(resolver.page.Total)
+}
+
+type customerEdgeResolver struct {
+	cursor string
+	node   customer
+}
+
+func (resolver *customerEdgeResolver) Cursor() string {
+	return resolver.cursor
+}
+
+func (resolver *customerEdgeResolver) Node() *customerResolver {
+	return &customerResolver{resolver.node}
+}
+
+type pageInfoResolver struct {
+	page customerPage
+}
+
+func (resolver *pageInfoResolver) HasNextPage() bool {
+	return resolver.page.Next != ""
+}
+
+func (resolver *pageInfoResolver) EndCursor() *string {
+	if resolver.page.Next == "" {
+		return nil
+	}
+
+	return &resolver.page.Next
+}
+
+// customerFilterInput has the filters of each field of a customer.
+type customerFilterInput struct {
+	ID        *stringFilterInput
+	Name      *stringFilterInput
+	Surname   *stringFilterInput
+	Email     *stringFilterInput
+	Birthdate *stringFilterInput
+}
+
+// addTo writes the filters as the query parameters of GET /customers.
+func (input customerFilterInput) addTo(parameters url.Values) {
+	fieldFilters := map[string]*stringFilterInput{
+		customerIdField:        input.ID,
+		"name":                 input.Name,
+		"surname":              input.Surname,
+		"email":                input.Email,
+		customerBirthdateField: input.Birthdate,
+	}
+
+	for field, fieldFilter := range fieldFilters {
+		if fieldFilter == nil {
+			continue
+		}
+
+		for operator, value := range fieldFilter.getValues() {
+			parameters.Set(fmt.Sprintf("%s[%s]", field, operator), value)
+		}
+	}
+}
+
+type stringFilterInput struct {
+	Eq        *string
+	Ieq       *string
+	Prefix    *string
+	Iprefix   *string
+	Icontains *string
+	Gt        *string
+	Gte       *string
+	Lt        *string
+	Lte       *string
+}
+
+// getValues returns the value of each operator that was sent.
+func (input stringFilterInput) getValues() map[filterOperator]string {
+	operators := map[filterOperator]*string{
+		equalOperator:                   input.Eq,
+		caseInsensitiveEqualOperator:    input.Ieq,
+		prefixOperator:                  input.Prefix,
+		caseInsensitivePrefixOperator:   input.Iprefix,
+		caseInsensitiveContainsOperator: input.Icontains,
+		greaterThanOperator:             input.Gt,
+		greaterThanOrEqualOperator:      input.Gte,
+		lessThanOperator:                input.Lt,
+		lessThanOrEqualOperator:         input.Lte,
+	}
+
+	values := map[filterOperator]string{}
+
+	for operator, value := range operators {
+		if value != nil {
+			values[operator] = *value
+		}
+	}
+
+	return values
+}
+
+// graphqlError is a problem returned by a resolver. Its type, status code
+// and invalid fields are sent in the extensions of the GraphQL error.
+type graphqlError struct {
+	problem *problem
+}
+
+// newGraphqlError translates err, which is a problem or an error returned
+// by the repository, into a GraphQL error.
+func newGraphqlError(err error) error {
+	graphqlProblem := newRepositoryProblem(err)
+
+	if graphqlProblem.Status == http.StatusInternalServerError {
+		log.Printf("GraphQL error: %v", err)
+	}
+
+	return graphqlError{graphqlProblem}
+}
+
+func (err graphqlError) Error() string {
+	return err.problem.Error()
+}
+
+func (err graphqlError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"type": err.problem.Type, "status": err.problem.Status}
+
+	if len(err.problem.Errors) > 0 {
+		extensions["errors"] = err.problem.Errors
+	}
+
+	return extensions
+}
+
+// graphiqlPage loads GraphiQL from a CDN and points it to /graphql.
+const graphiqlPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<title>Customers API - GraphiQL</title>
+	<link rel="stylesheet" href="https://unpkg.com/graphiql@3/graphiql.min.css">
+	<style>body { margin: 0; height: 100vh; } #graphiql { height: 100vh; }</style>
+</head>
+<body>
+	<div id="graphiql"></div>
+	<script crossorigin src="https://unpkg.com/react@18/umd/react.production.min.js"></script>
+	<script crossorigin src="https://unpkg.com/react-dom@18/umd/react-dom.production.min.js"></script>
+	<script crossorigin src="https://unpkg.com/graphiql@3/graphiql.min.js"></script>
+	<script>
+		const fetcher = GraphiQL.createFetcher({ url: "/graphql" });
+		ReactDOM.createRoot(document.getElementById("graphiql")).render(React.createElement(GraphiQL, { fetcher }));
+	</script>
+</body>
+</html>
+`
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type graphqlResponseForTesting struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func sendGraphqlRequestForTesting(t *testing.T, router *gin.Engine, query string, variables map[string]interface{}) graphqlResponseForTesting {
+	writer := sendRequestForTesting(router, "POST", "/graphql", graphqlRequest{Query: query, Variables: variables})
+	assert.Equal(t, 200, writer.Code)
+
+	var got graphqlResponseForTesting
+
+	if err := json.Unmarshal(writer.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	return got
+}
+
+func TestGraphqlMutations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	got := sendGraphqlRequestForTesting(t, router, `mutation ($input: CreateCustomerInput!) {
+		createCustomer(input: $input) { id name version }
+	}`, map[string]interface{}{"input": getMockedCustomer()})
+
+	assert.Empty(t, got.Errors)
+	assert.JSONEq(t, `{"id": "1", "name": "Augusto", "version": 1}`, string(got.Data["createCustomer"]))
+
+	got = sendGraphqlRequestForTesting(t, router, `mutation {
+		updateCustomer(id: "1", expectedVersion: 1, input: {name: "John", surname: "Wick", email: "john.wick@gmail.com", birthdate: "1964-09-02"}) { surname version }
+	}`, nil)
+
+	assert.Empty(t, got.Errors)
+	assert.JSONEq(t, `{"surname": "Wick", "version": 2}`, string(got.Data["updateCustomer"]))
+
+	got = sendGraphqlRequestForTesting(t, router, `mutation { deleteCustomer(id: "1", expectedVersion: 1) }`, nil)
+	assert.Equal(t, "Customer was modified: The customer doesn't have the ETag sent in If-Match", got.Errors[0].Message)
+	assert.Equal(t, versionMismatchProblemType, got.Errors[0].Extensions["type"])
+
+	// A version a customer can't have isn't taken as any version.
+	for _, expectedVersion := range []int{0, -5} {
+		got = sendGraphqlRequestForTesting(t, router, `mutation ($version: Int) { deleteCustomer(id: "1", expectedVersion: $version) }`,
+			map[string]interface{}{"version": expectedVersion})
+		assert.Len(t, got.Errors, 1)
+		assert.Equal(t, validationProblemType, got.Errors[0].Extensions["type"])
+		assert.Equal(t, float64(400), got.Errors[0].Extensions["status"])
+	}
+
+	got = sendGraphqlRequestForTesting(t, router, `mutation { deleteCustomer(id: "1") }`, nil)
+	assert.Empty(t, got.Errors)
+	assert.JSONEq(t, `"1"`, string(got.Data["deleteCustomer"]))
+
+	got = sendGraphqlRequestForTesting(t, router, `{ customer(id: "1") { id } }`, nil)
+	assert.Empty(t, got.Errors)
+	assert.JSONEq(t, `null`, string(got.Data["customer"]))
+}
+
+func TestGraphqlMutationsWhenExpectedVersionIsRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	requireIfMatch = true
+	router := setupRouter()
+
+	defer func() {
+		requireIfMatch = false
+		clearCustomers(nil)
+	}()
+
+	got := sendGraphqlRequestForTesting(t, router, `mutation ($input: CreateCustomerInput!) {
+		createCustomer(input: $input) { id }
+	}`, map[string]interface{}{"input": getMockedCustomer()})
+
+	assert.Empty(t, got.Errors)
+
+	got = sendGraphqlRequestForTesting(t, router, `mutation {
+		updateCustomer(id: "1", input: {name: "John", surname: "Wick", email: "john.wick@gmail.com", birthdate: "1964-09-02"}) { version }
+	}`, nil)
+
+	assert.Len(t, got.Errors, 1)
+	assert.Equal(t, "about:blank", got.Errors[0].Extensions["type"])
+	assert.Equal(t, float64(428), got.Errors[0].Extensions["status"])
+
+	got = sendGraphqlRequestForTesting(t, router, `mutation { deleteCustomer(id: "1") }`, nil)
+	assert.Len(t, got.Errors, 1)
+	assert.Equal(t, float64(428), got.Errors[0].Extensions["status"])
+
+	got = sendGraphqlRequestForTesting(t, router, `mutation {
+		updateCustomer(id: "1", expectedVersion: 1, input: {name: "John", surname: "Wick", email: "john.wick@gmail.com", birthdate: "1964-09-02"}) { version }
+	}`, nil)
+
+	assert.Empty(t, got.Errors)
+	assert.JSONEq(t, `{"version": 2}`, string(got.Data["updateCustomer"]))
+
+	got = sendGraphqlRequestForTesting(t, router, `mutation { deleteCustomer(id: "1", expectedVersion: 2) }`, nil)
+	assert.Empty(t, got.Errors)
+}
+
+func TestGraphqlValidationErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	got := sendGraphqlRequestForTesting(t, router, `mutation {
+		createCustomer(input: {name: "", surname: "Wick", email: "not an email", birthdate: "1964-09-02"}) { id }
+	}`, nil)
+
+	assert.Len(t, got.Errors, 1)
+	assert.Equal(t, validationProblemType, got.Errors[0].Extensions["type"])
+	assert.Equal(t, float64(400), got.Errors[0].Extensions["status"])
+	assert.Len(t, got.Errors[0].Extensions["errors"], 2)
+
+	got = sendGraphqlRequestForTesting(t, router, `{ customers(first: 0, filter: {name: {gt: "A"}}) { totalCount } }`, nil)
+
+	assert.Len(t, got.Errors, 1)
+	assert.Equal(t, invalidQueryProblemType, got.Errors[0].Extensions["type"])
+
+	fields := []string{}
+
+	for _, fieldError := range got.Errors[0].Extensions["errors"].([]interface{}) {
+		fields = append(fields, fieldError.(map[string]interface{})["field"].(string))
+	}
+
+	assert.Equal(t, []string{"first", "name[gt]"}, fields)
+}
+
+func TestGraphqlCustomersConnection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	sendRequestForTesting(router, "POST", "/customers/bulk", getMockedCustomersForFiltering())
+
+	query := `query ($after: String) {
+		customers(filter: {birthdate: {lt: "1990-06-01"}}, sort: "birthdate", first: 2, after: $after) {
+			edges { cursor node { id } }
+			pageInfo { hasNextPage endCursor }
+			totalCount
+		}
+	}`
+
+	type connection struct {
+		Edges []struct {
+			Cursor string
+			Node   struct{ ID string }
+		}
+		PageInfo struct {
+			HasNextPage bool
+			EndCursor   *string
+		}
+		TotalCount int
+	}
+
+	got := sendGraphqlRequestForTesting(t, router, query, nil)
+	assert.Empty(t, got.Errors)
+
+	var firstPage connection
+	assert.NoError(t, json.Unmarshal(got.Data["customers"], &firstPage))
+	assert.Equal(t, 3, firstPage.TotalCount)
+	assert.Len(t, firstPage.Edges, 2)
+	assert.Equal(t, []string{"2", "3"}, []string{firstPage.Edges[0].Node.ID, firstPage.Edges[1].Node.ID})
+	assert.True(t, firstPage.PageInfo.HasNextPage)
+	assert.Equal(t, firstPage.Edges[1].Cursor, *firstPage.PageInfo.EndCursor)
+
+	// The cursor of any edge can be used to continue after it.
+	got = sendGraphqlRequestForTesting(t, router, query, map[string]interface{}{"after": firstPage.Edges[0].Cursor})
+
+	var secondPage connection
+	assert.NoError(t, json.Unmarshal(got.Data["customers"], &secondPage))
+	assert.Equal(t, []string{"3", "4"}, []string{secondPage.Edges[0].Node.ID, secondPage.Edges[1].Node.ID})
+	assert.False(t, secondPage.PageInfo.HasNextPage)
+	assert.Nil(t, secondPage.PageInfo.EndCursor)
+}
+
+func TestGraphqlRequestErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	writer := sendRequestForTesting(router, "POST", "/graphql", graphqlRequest{})
+	assert.Equal(t, 400, writer.Code)
+	assert.Equal(t, invalidBodyProblemType, getProblemFromResponse(t, writer)["type"])
+
+	// Only the newer media type gets a 400 code for invalid queries.
+	for accept, expectedStatus := range map[string]int{gin.MIMEJSON: 200, graphqlResponseContentType: 400} {
+		request := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ unknown }"}`))
+		request.Header.Set("Content-Type", gin.MIMEJSON)
+		request.Header.Set("Accept", accept)
+
+		writer := httptest.NewRecorder()
+		router.ServeHTTP(writer, request)
+
+		assert.Equal(t, expectedStatus, writer.Code, accept)
+		assert.Equal(t, accept+"; charset=utf-8", writer.Header().Get("Content-Type"), accept)
+	}
+
+	writer = sendRequestForTesting(router, "GET", "/graphiql", nil)
+	assert.Equal(t, 404, writer.Code)
+
+	gin.SetMode(gin.DebugMode)
+	defer gin.SetMode(gin.TestMode)
+
+	writer = sendRequestForTesting(setupRouter(), "GET", "/graphiql", nil)
+	assert.Equal(t, 200, writer.Code)
+	assert.Contains(t, writer.Body.String(), "GraphiQL")
+}
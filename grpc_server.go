@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"codesherpas/customer_api/customerpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// customerGrpcServer serves CustomerService with the same repository and
+// validations as the HTTP API.
+type customerGrpcServer struct {
+	customerpb.UnimplementedCustomerServiceServer
+}
+
+// newGrpcServer returns a gRPC server with CustomerService registered.
+// Reflection is also registered, so tools like grpcurl can list the
+// methods. Calls need the same API keys as the HTTP API.
+func newGrpcServer() *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(grpcAuthUnaryInterceptor(authSettings)),
+		grpc.ChainStreamInterceptor(grpcAuthStreamInterceptor(authSettings)),
+	)
+	customerpb.RegisterCustomerServiceServer(server, customerGrpcServer{})
+	reflection.Register(server)
+
+	return server
+}
+
+func (customerGrpcServer) CreateCustomer(ctx context.Context, request *customerpb.CreateCustomerRequest) (*customerpb.Customer, error) {
+	createdCustomer, err := createCustomer(ctx, repository, newCustomerFromProto(request.GetCustomer()))
+
+	if err != nil {
+		return nil, newGrpcError(err)
+	}
+
+	return newCustomerProto(createdCustomer), nil
+}
+
+func (customerGrpcServer) GetCustomer(ctx context.Context, request *customerpb.GetCustomerRequest) (*customerpb.Customer, error) {
+	if err := validateGrpcCustomerId(request.GetId()); err != nil {
+		return nil, err
+	}
+
+	storedCustomer, err := repository.Get(ctx, request.GetId())
+
+	if err != nil {
+		return nil, newGrpcError(err)
+	}
+
+	return newCustomerProto(storedCustomer), nil
+}
+
+// ListCustomers sends the customers a page at a time, as they're read from
+// the repository.
+func (customerGrpcServer) ListCustomers(request *customerpb.ListCustomersRequest, stream customerpb.CustomerService_ListCustomersServer) error {
+	parameters := url.Values{}
+
+	for name, value := range request.GetFilters() {
+		parameters.Set(name, value)
+	}
+
+	if request.GetSort() != "" {
+		parameters.Set(listSortParameter, request.GetSort())
+	}
+
+	sortKeys, fieldErrors := parseSort(parameters)
+	filters, filterErrors := parseFilters(parameters)
+	fieldErrors = append(fieldErrors, filterErrors...)
+
+	if len(fieldErrors) > 0 {
+		return newGrpcError(newInvalidQueryProblem(fieldErrors))
+	}
+
+	query := listQuery{Filters: filters, Sort: sortKeys}
+
+	err := forEachMatchingCustomer(stream.Context(), repository, query, func(storedCustomer customer) error {
+		return stream.Send(newCustomerProto(storedCustomer))
+	})
+
+	if err != nil {
+		return newGrpcError(err)
+	}
+
+	return nil
+}
+
+func (customerGrpcServer) UpdateCustomer(ctx context.Context, request *customerpb.UpdateCustomerRequest) (*customerpb.Customer, error) {
+	newCustomer := newCustomerFromProto(request.GetCustomer())
+
+	if err := validateGrpcCustomerId(newCustomer.ID); err != nil {
+		return nil, err
+	}
+
+	if fieldErrors := validateCustomer(newCustomer); len(fieldErrors) > 0 {
+		return nil, newGrpcError(newValidationProblem(fieldErrors))
+	}
+
+	if err := checkGrpcExpectedVersionIsSent(request.GetExpectedVersion()); err != nil {
+		return nil, err
+	}
+
+	updatedCustomer, err := repository.Update(ctx, newCustomer.ID, newCustomer, expectVersion(request.GetExpectedVersion())...)
+
+	if err != nil {
+		return nil, newGrpcError(err)
+	}
+
+	return newCustomerProto(updatedCustomer), nil
+}
+
+func (customerGrpcServer) DeleteCustomer(ctx context.Context, request *customerpb.DeleteCustomerRequest) (*customerpb.DeleteCustomerResponse, error) {
+	if err := validateGrpcCustomerId(request.GetId()); err != nil {
+		return nil, err
+	}
+
+	if err := checkGrpcExpectedVersionIsSent(request.GetExpectedVersion()); err != nil {
+		return nil, err
+	}
+
+	if err := repository.Delete(ctx, request.GetId(), expectVersion(request.GetExpectedVersion())...); err != nil {
+		return nil, newGrpcError(err)
+	}
+
+	return &customerpb.DeleteCustomerResponse{}, nil
+}
+
+func newCustomerFromProto(message *customerpb.Customer) customer {
+	return customer{
+		ID:        message.GetId(),
+		Name:      message.GetName(),
+		Surname:   message.GetSurname(),
+		Email:     message.GetEmail(),
+		Birthdate: message.GetBirthdate(),
+	}
+}
+
+func newCustomerProto(storedCustomer customer) *customerpb.Customer {
+	return &customerpb.Customer{
+		Id:        storedCustomer.ID,
+		Name:      storedCustomer.Name,
+		Surname:   storedCustomer.Surname,
+		Email:     storedCustomer.Email,
+		Birthdate: storedCustomer.Birthdate,
+		Version:   storedCustomer.Version,
+	}
+}
+
+// checkGrpcExpectedVersionIsSent is the gRPC counterpart of
+// checkIfMatchIsSent: when If-Match is required, changes must have an
+// expected version, since 0 means any version.
+func checkGrpcExpectedVersionIsSent(expectedVersion uint64) error {
+	if requireIfMatch && expectedVersion == 0 {
+		return newGrpcError(newPreconditionRequiredProblem("The expected version of the customer is required"))
+	}
+
+	return nil
+}
+
+func validateGrpcCustomerId(id string) error {
+	if fieldError := validateCustomerId(id); fieldError != nil {
+		return newGrpcError(newProblem(http.StatusBadRequest, invalidIdProblemType, fieldError.Message, ""))
+	}
+
+	return nil
+}
+
+// newGrpcError translates err, which is a problem or an error returned by
+// the repository, into the gRPC status closest to its HTTP status code.
+// Invalid fields are sent as a BadRequest detail.
+func newGrpcError(err error) error {
+	grpcProblem := newRepositoryProblem(err)
+	code := codes.Internal
+
+	switch grpcProblem.Status {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.AlreadyExists
+	case http.StatusPreconditionFailed:
+		code = codes.Aborted
+	case http.StatusPreconditionRequired:
+		code = codes.FailedPrecondition
+	default:
+		// Errors of the stream, like a client that went away, already
+		// have a status.
+		if grpcStatus, isStatus := status.FromError(err); isStatus {
+			return grpcStatus.Err()
+		}
+
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return status.FromContextError(err).Err()
+		}
+
+		log.Printf("gRPC error: %v", err)
+	}
+
+	grpcStatus := status.New(code, grpcProblem.Error())
+
+	if len(grpcProblem.Errors) == 0 {
+		return grpcStatus.Err()
+	}
+
+	badRequest := &errdetails.BadRequest{}
+
+	for _, fieldError := range grpcProblem.Errors {
+		badRequest.FieldViolations = append(badRequest.FieldViolations,
+			&errdetails.BadRequest_FieldViolation{Field: fieldError.Field, Description: fieldError.Message})
+	}
+
+	if detailedStatus, err := grpcStatus.WithDetails(badRequest); err == nil {
+		grpcStatus = detailedStatus
+	}
+
+	return grpcStatus.Err()
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"codesherpas/customer_api/customerpb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// getGrpcClientForTesting serves CustomerService on an in-process listener
+// and returns a client connected to it.
+func getGrpcClientForTesting(t *testing.T) customerpb.CustomerServiceClient {
+	listener := bufconn.Listen(1024 * 1024)
+	server := newGrpcServer()
+
+	go server.Serve(listener)
+
+	connection, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		connection.Close()
+		server.Stop()
+	})
+
+	return customerpb.NewCustomerServiceClient(connection)
+}
+
+func getCustomerProtoForTesting() *customerpb.Customer {
+	return newCustomerProto(getMockedCustomer())
+}
+
+func listCustomersForTesting(t *testing.T, client customerpb.CustomerServiceClient, request *customerpb.ListCustomersRequest) ([]string, error) {
+	stream, err := client.ListCustomers(context.Background(), request)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []string{}
+
+	for {
+		message, err := stream.Recv()
+
+		if errors.Is(err, io.EOF) {
+			return ids, nil
+		} else if err != nil {
+			return ids, err
+		}
+
+		ids = append(ids, message.GetId())
+	}
+}
+
+func TestGrpcCustomerService(t *testing.T) {
+	repository = newInMemoryCustomerRepository()
+	client := getGrpcClientForTesting(t)
+	ctx := context.Background()
+
+	defer clearCustomers(nil)
+
+	created, err := client.CreateCustomer(ctx, &customerpb.CreateCustomerRequest{Customer: getCustomerProtoForTesting()})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), created.GetVersion())
+
+	got, err := client.GetCustomer(ctx, &customerpb.GetCustomerRequest{Id: "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, getMockedCustomer(), newCustomerFromProto(got))
+
+	// The HTTP API and the gRPC service share the repository.
+	stored, err := repository.Get(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, withVersion(getMockedCustomer(), 1), stored)
+
+	updatedCustomer := newCustomerProto(getMockedUpdatedCustomerInformation())
+
+	_, err = client.UpdateCustomer(ctx, &customerpb.UpdateCustomerRequest{Customer: updatedCustomer, ExpectedVersion: 2})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	updated, err := client.UpdateCustomer(ctx, &customerpb.UpdateCustomerRequest{Customer: updatedCustomer, ExpectedVersion: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), updated.GetVersion())
+	assert.Equal(t, getMockedUpdatedCustomerInformation(), newCustomerFromProto(updated))
+
+	_, err = client.DeleteCustomer(ctx, &customerpb.DeleteCustomerRequest{Id: "1"})
+	assert.NoError(t, err)
+
+	_, err = client.GetCustomer(ctx, &customerpb.GetCustomerRequest{Id: "1"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGrpcCustomerServiceErrors(t *testing.T) {
+	repository = newInMemoryCustomerRepository()
+	client := getGrpcClientForTesting(t)
+	ctx := context.Background()
+
+	defer clearCustomers(nil)
+
+	invalidCustomer := getCustomerProtoForTesting()
+	invalidCustomer.Name = ""
+	invalidCustomer.Email = "not an email"
+
+	_, err := client.CreateCustomer(ctx, &customerpb.CreateCustomerRequest{Customer: invalidCustomer})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	details := status.Convert(err).Details()
+	assert.Len(t, details, 1)
+
+	badRequest, isBadRequest := details[0].(*errdetails.BadRequest)
+	assert.True(t, isBadRequest)
+	assert.Equal(t, "name", badRequest.GetFieldViolations()[0].GetField())
+	assert.Equal(t, "email", badRequest.GetFieldViolations()[1].GetField())
+
+	_, err = client.CreateCustomer(ctx, &customerpb.CreateCustomerRequest{Customer: getCustomerProtoForTesting()})
+	assert.NoError(t, err)
+
+	_, err = client.CreateCustomer(ctx, &customerpb.CreateCustomerRequest{Customer: getCustomerProtoForTesting()})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = client.GetCustomer(ctx, &customerpb.GetCustomerRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.DeleteCustomer(ctx, &customerpb.DeleteCustomerRequest{Id: "2"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGrpcCustomerServiceWhenExpectedVersionIsRequired(t *testing.T) {
+	repository = newInMemoryCustomerRepository()
+	requireIfMatch = true
+	client := getGrpcClientForTesting(t)
+	ctx := context.Background()
+
+	defer func() {
+		requireIfMatch = false
+		clearCustomers(nil)
+	}()
+
+	_, err := client.CreateCustomer(ctx, &customerpb.CreateCustomerRequest{Customer: getCustomerProtoForTesting()})
+	assert.NoError(t, err)
+
+	updatedCustomer := newCustomerProto(getMockedUpdatedCustomerInformation())
+
+	_, err = client.UpdateCustomer(ctx, &customerpb.UpdateCustomerRequest{Customer: updatedCustomer})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = client.DeleteCustomer(ctx, &customerpb.DeleteCustomerRequest{Id: "1"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	stored, err := repository.Get(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, withVersion(getMockedCustomer(), 1), stored)
+
+	_, err = client.UpdateCustomer(ctx, &customerpb.UpdateCustomerRequest{Customer: updatedCustomer, ExpectedVersion: 1})
+	assert.NoError(t, err)
+
+	_, err = client.DeleteCustomer(ctx, &customerpb.DeleteCustomerRequest{Id: "1", ExpectedVersion: 2})
+	assert.NoError(t, err)
+}
+
+func TestGrpcListCustomers(t *testing.T) {
+	repository = newInMemoryCustomerRepository()
+	client := getGrpcClientForTesting(t)
+
+	defer clearCustomers(nil)
+
+	for _, newCustomer := range getMockedCustomersForFiltering() {
+		_, err := repository.Create(context.Background(), newCustomer)
+		assert.NoError(t, err)
+	}
+
+	ids, err := listCustomersForTesting(t, client, &customerpb.ListCustomersRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, ids)
+
+	ids, err = listCustomersForTesting(t, client, &customerpb.ListCustomersRequest{
+		Filters: map[string]string{"surname": "Wick"},
+		Sort:    "-name",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2", "3"}, ids)
+
+	_, err = listCustomersForTesting(t, client, &customerpb.ListCustomersRequest{Sort: "phone"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+)
+
+// idGenerator allocates the IDs of the customers added without one.
+type idGenerator interface {
+	Generate() string
+}
+
+// idAssignment decides who is in charge of choosing the ID of a new
+// customer.
+type idAssignment string
+
+const (
+	// clientIdAssignment requires the clients to send the ID.
+	clientIdAssignment idAssignment = "client"
+	// optionalIdAssignment generates an ID when the client doesn't send
+	// one.
+	optionalIdAssignment idAssignment = "optional"
+	// serverIdAssignment always generates the ID and rejects the ones
+	// sent by the clients.
+	serverIdAssignment idAssignment = "server"
+)
+
+// customerIdGenerator and customerIdAssignment are used by postCustomer.
+// They're replaced on startup by the ones selected with
+// ids.generator and ids.assignment.
+var (
+	customerIdGenerator  idGenerator  = newULIDIdGenerator()
+	customerIdAssignment idAssignment = optionalIdAssignment
+)
+
+// parseIdAssignment validates the name of an idAssignment.
+func parseIdAssignment(name string) (idAssignment, error) {
+	switch assignment := idAssignment(name); assignment {
+	case clientIdAssignment, optionalIdAssignment, serverIdAssignment:
+		return assignment, nil
+	default:
+		return "", fmt.Errorf("unknown ID assignment %q", name)
+	}
+}
+
+// newIdGenerator returns the generator with the given name: "ulid", "uuid"
+// or "sequence". The sequence continues after the highest integer ID already
+// stored in the repository.
+func newIdGenerator(ctx context.Context, name string, repository CustomerRepository) (idGenerator, error) {
+	switch name {
+	case "ulid":
+		return newULIDIdGenerator(), nil
+	case "uuid":
+		return uuidIdGenerator{}, nil
+	case "sequence":
+		var highestId uint64
+
+		err := forEachCustomer(ctx, repository, func(customer customer) error {
+			if id, err := strconv.ParseUint(customer.ID, 10, 64); err == nil && id > highestId {
+				highestId = id
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		return newSequenceIdGenerator(highestId), nil
+	default:
+		return nil, fmt.Errorf("unknown ID generator %q", name)
+	}
+}
+
+// ulidIdGenerator generates ULIDs. IDs generated within the same
+// millisecond are still increasing, so they sort in creation order.
+type ulidIdGenerator struct {
+	mutex   sync.Mutex
+	entropy *ulid.MonotonicEntropy
+}
+
+func newULIDIdGenerator() *ulidIdGenerator {
+	return &ulidIdGenerator{entropy: ulid.Monotonic(rand.Reader, 0)}
+}
+
+func (generator *ulidIdGenerator) Generate() string {
+	// MonotonicEntropy isn't safe for concurrent use.
+	generator.mutex.Lock()
+	defer generator.mutex.Unlock()
+
+	return ulid.MustNew(ulid.Timestamp(time.Now()), generator.entropy).String()
+}
+
+// uuidIdGenerator generates random (version 4) UUIDs.
+type uuidIdGenerator struct{}
+
+func (uuidIdGenerator) Generate() string {
+	return uuid.NewString()
+}
+
+// sequenceIdGenerator generates increasing positive integers.
+type sequenceIdGenerator struct {
+	mutex sync.Mutex
+	last  uint64
+}
+
+// newSequenceIdGenerator returns a generator whose first ID is last + 1.
+func newSequenceIdGenerator(last uint64) *sequenceIdGenerator {
+	return &sequenceIdGenerator{last: last}
+}
+
+func (generator *sequenceIdGenerator) Generate() string {
+	generator.mutex.Lock()
+	defer generator.mutex.Unlock()
+
+	generator.last++
+
+	return strconv.FormatUint(generator.last, 10)
+}
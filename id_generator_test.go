@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestULIDIdGeneratorIsIncreasing(t *testing.T) {
+	generator := newULIDIdGenerator()
+	previousId := generator.Generate()
+
+	for i := 0; i < 1000; i++ {
+		id := generator.Generate()
+
+		assert.NoError(t, ulidIdPolicy{}.Validate(id))
+		assert.Greater(t, id, previousId)
+
+		previousId = id
+	}
+}
+
+func TestUUIDIdGeneratorFollowsUUIDPolicy(t *testing.T) {
+	generator, err := newIdGenerator(context.Background(), "uuid", newInMemoryCustomerRepository())
+	assert.NoError(t, err)
+
+	ids := map[string]bool{}
+
+	for i := 0; i < 1000; i++ {
+		id := generator.Generate()
+
+		assert.NoError(t, uuidIdPolicy{}.Validate(id))
+		assert.False(t, ids[id])
+
+		ids[id] = true
+	}
+}
+
+func TestSequenceIdGeneratorContinuesAfterHighestId(t *testing.T) {
+	repository := newInMemoryCustomerRepository()
+
+	for _, id := range []string{"3", "abc", "12", "007"} {
+		mockedCustomer := getMockedCustomer()
+		mockedCustomer.ID = id
+
+		_, err := repository.Create(context.Background(), mockedCustomer)
+		assert.NoError(t, err)
+	}
+
+	generator, err := newIdGenerator(context.Background(), "sequence", repository)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "13", generator.Generate())
+	assert.Equal(t, "14", generator.Generate())
+}
+
+func TestUnknownIdGeneratorAndAssignment(t *testing.T) {
+	_, err := newIdGenerator(context.Background(), "random", newInMemoryCustomerRepository())
+	assert.Error(t, err)
+
+	_, err = parseIdAssignment("anyone")
+	assert.Error(t, err)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+)
+
+// idPolicy decides which customer IDs are acceptable. The same policy is
+// applied to the IDs received on the path of every route and to the ones
+// sent on the body when adding a customer.
+type idPolicy interface {
+	Validate(id string) error
+}
+
+// customerIdPolicy is the policy used by validateId. It's replaced on
+// startup by the one selected with ids.policy.
+var customerIdPolicy idPolicy = newOpaqueIdPolicy()
+
+var errInvalidId = errors.New("ID is not valid")
+
+// newIdPolicy returns the policy with the given name: "opaque", "uuid",
+// "ulid" or "integer".
+func newIdPolicy(name string) (idPolicy, error) {
+	switch name {
+	case "opaque":
+		return newOpaqueIdPolicy(), nil
+	case "uuid":
+		return uuidIdPolicy{}, nil
+	case "ulid":
+		return ulidIdPolicy{}, nil
+	case "integer":
+		return integerIdPolicy{}, nil
+	default:
+		return nil, fmt.Errorf("unknown ID policy %q", name)
+	}
+}
+
+// opaqueIdPolicy accepts any string made of the allowed characters whose
+// length doesn't exceed maxLength.
+type opaqueIdPolicy struct {
+	allowedCharacters string
+	maxLength         int
+}
+
+// newOpaqueIdPolicy accepts up to 64 characters that don't need to be
+// escaped on a URL.
+func newOpaqueIdPolicy() opaqueIdPolicy {
+	return opaqueIdPolicy{
+		allowedCharacters: "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-._~",
+		maxLength:         64,
+	}
+}
+
+func (policy opaqueIdPolicy) Validate(id string) error {
+	if len(id) > policy.maxLength {
+		return fmt.Errorf("%w: it can't be longer than %d characters", errInvalidId, policy.maxLength)
+	}
+
+	for _, character := range id {
+		if !strings.ContainsRune(policy.allowedCharacters, character) {
+			return fmt.Errorf("%w: %q isn't allowed", errInvalidId, character)
+		}
+	}
+
+	return nil
+}
+
+// uuidIdPolicy accepts version 4 UUIDs in their canonical (lowercase)
+// form.
+type uuidIdPolicy struct{}
+
+func (uuidIdPolicy) Validate(id string) error {
+	parsedId, err := uuid.Parse(id)
+
+	if err != nil || parsedId.String() != id {
+		return fmt.Errorf("%w: it must be a UUID", errInvalidId)
+	}
+
+	if parsedId.Version() != 4 || parsedId.Variant() != uuid.RFC4122 {
+		return fmt.Errorf("%w: it must be a version 4 UUID", errInvalidId)
+	}
+
+	return nil
+}
+
+// ulidIdPolicy accepts ULIDs in their canonical (uppercase) form.
+type ulidIdPolicy struct{}
+
+func (ulidIdPolicy) Validate(id string) error {
+	parsedId, err := ulid.ParseStrict(id)
+
+	if err != nil || parsedId.String() != id {
+		return fmt.Errorf("%w: it must be a ULID", errInvalidId)
+	}
+
+	return nil
+}
+
+// integerIdPolicy accepts positive integers written in base 10 without
+// leading zeros, so every number has a single valid ID.
+type integerIdPolicy struct{}
+
+func (integerIdPolicy) Validate(id string) error {
+	number, err := strconv.ParseUint(id, 10, 64)
+
+	if err != nil || number == 0 || strings.HasPrefix(id, "0") {
+		return fmt.Errorf("%w: it must be a positive integer", errInvalidId)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdPolicies(t *testing.T) {
+	tests := []struct {
+		policy  string
+		id      string
+		isValid bool
+	}{
+		{"opaque", "1", true},
+		{"opaque", "7", true},
+		{"opaque", "customer-42", true},
+		{"opaque", "a.b_c~d", true},
+		{"opaque", "01ARZ3NDEKTSV4RRFFQ69G5FAV", true},
+		{"opaque", strings.Repeat("a", 64), true},
+		{"opaque", strings.Repeat("a", 65), false},
+		{"opaque", "/", false},
+		{"opaque", "a b", false},
+		{"opaque", "ñ", false},
+		{"uuid", "f47ac10b-58cc-4372-a567-0e02b2c3d479", true},
+		{"uuid", "F47AC10B-58CC-4372-A567-0E02B2C3D479", false},
+		{"uuid", "{f47ac10b-58cc-4372-a567-0e02b2c3d479}", false},
+		{"uuid", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", false},
+		{"uuid", "1", false},
+		{"ulid", "01ARZ3NDEKTSV4RRFFQ69G5FAV", true},
+		{"ulid", "01arz3ndektsv4rrffq69g5fav", false},
+		{"ulid", "01ARZ3NDEKTSV4RRFFQ69G5FA", false},
+		{"ulid", "1", false},
+		{"integer", "1", true},
+		{"integer", "7", true},
+		{"integer", "123456789", true},
+		{"integer", "0", false},
+		{"integer", "-1", false},
+		{"integer", "+1", false},
+		{"integer", "007", false},
+		{"integer", "abc", false},
+		{"integer", "99999999999999999999", false},
+	}
+
+	for _, test := range tests {
+		policy, err := newIdPolicy(test.policy)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = policy.Validate(test.id)
+
+		if test.isValid {
+			assert.NoError(t, err, "%s policy with ID %q", test.policy, test.id)
+		} else {
+			assert.ErrorIs(t, err, errInvalidId, "%s policy with ID %q", test.policy, test.id)
+		}
+	}
+}
+
+func TestUnknownIdPolicy(t *testing.T) {
+	_, err := newIdPolicy("base6")
+
+	assert.Error(t, err)
+}
+
+func TestIdPolicyIsAppliedToEveryRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	customerIdPolicy = integerIdPolicy{}
+	router := setupRouter()
+
+	defer func() {
+		customerIdPolicy = newOpaqueIdPolicy()
+		clearCustomers(nil)
+	}()
+
+	mockedCustomer := getMockedCustomer()
+	mockedCustomer.ID = "abc"
+
+	writer := sendRequestForTesting(router, "POST", "/customer", mockedCustomer)
+	assert.Equal(t, 400, writer.Code)
+
+	mockedCustomer.ID = "9"
+
+	writer = sendRequestForTesting(router, "POST", "/customer", mockedCustomer)
+	assert.Equal(t, 201, writer.Code)
+
+	for _, method := range []string{"GET", "PUT", "DELETE"} {
+		writer = sendRequestForTesting(router, method, "/customer/abc", mockedCustomer)
+		assert.Equal(t, 400, writer.Code, method)
+	}
+
+	for _, method := range []string{"GET", "PUT", "DELETE"} {
+		writer = sendRequestForTesting(router, method, "/customer/9", mockedCustomer)
+		assert.Equal(t, 200, writer.Code, method)
+	}
+}
@@ -1,16 +1,156 @@
 package main
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
-var customers = []customer{}
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+)
+
+// repository is where the handlers store and look up customers.
+var repository CustomerRepository = newInMemoryCustomerRepository()
 
 func main() {
-	router := gin.Default()
-	router.POST("/customer", postCustomer)
-	router.GET("/customers", getCustomers)
-	router.GET("/customer/:id", getCustomerById)
-	router.PUT("/customer/:id", updateCustomer)
-	router.DELETE("/customer/:id", deleteCustomer)
-
-	router.Run(":8080")
+	configuration, err := loadConfig(os.Args[1:], os.LookupEnv)
+
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	gin.SetMode(configuration.Mode)
+
+	configuredRepository, err := newRepositoryFromConfig(configuration.Storage)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	searchableRepository, err := newSearchableCustomerRepository(context.Background(), configuredRepository)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	repository = searchableRepository
+
+	// The names were validated with the rest of the configuration.
+	customerIdPolicy, _ = newIdPolicy(configuration.IDs.Policy)
+	customerIdAssignment, _ = parseIdAssignment(configuration.IDs.Assignment)
+
+	idGeneratorName := configuration.IDs.Generator
+	configuredIdGenerator, err := newIdGenerator(context.Background(), idGeneratorName, repository)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	customerIdGenerator = configuredIdGenerator
+
+	requireIfMatch = configuration.RequireIfMatch
+	validateRequests = configuration.ValidateRequests
+	corsSettings = configuration.CORS
+	authSettings = configuration.Auth
+
+	httpListener, err := net.Listen("tcp", configuration.HTTP.Address)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var grpcServer *grpc.Server
+	var grpcListener net.Listener
+
+	if configuration.GRPC.Address != "" {
+		grpcServer = newGrpcServer()
+		grpcListener, err = net.Listen("tcp", configuration.GRPC.Address)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	server := &http.Server{
+		Handler:      withStreamingDeadlines(setupRouter(), configuration.HTTP.WriteTimeout),
+		ReadTimeout:  configuration.HTTP.ReadTimeout,
+		WriteTimeout: configuration.HTTP.WriteTimeout,
+		IdleTimeout:  configuration.HTTP.IdleTimeout,
+	}
+
+	// Docker sends SIGTERM to stop the container, and Ctrl+C sends SIGINT.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Listening and serving HTTP on %s", httpListener.Addr())
+	err = serve(ctx, server, httpListener, grpcServer, grpcListener, configuration.ShutdownTimeout)
+
+	// The customers are only saved once nothing can change them anymore.
+	if closer, isCloser := configuredRepository.(io.Closer); isCloser {
+		err = errors.Join(err, closer.Close())
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Print("Stopped")
+}
+
+// setupRouter registers every endpoint of the API. Every error is
+// responded with a problem (RFC 7807).
+func setupRouter() *gin.Engine {
+	router := gin.New()
+	router.HandleMethodNotAllowed = true
+	router.Use(gin.Logger(), gin.CustomRecovery(recoverWithProblem), problemMiddleware(), corsMiddleware(corsSettings),
+		authMiddleware(authSettings), negotiationMiddleware())
+
+	if validateRequests || validateResponses {
+		router.Use(openapiValidationMiddleware(validateRequests, validateResponses))
+	}
+
+	router.NoRoute(noRouteProblem)
+	router.NoMethod(noMethodProblem)
+
+	registerApiVersions(router)
+	router.POST("/graphql", postGraphql)
+	router.GET("/openapi.json", getOpenapiDocument)
+	router.GET("/docs", getSwaggerUi)
+
+	if gin.Mode() == gin.DebugMode {
+		router.GET("/graphiql", getGraphiql)
+	}
+
+	return router
+}
+
+// newRepositoryFromConfig builds the repository of the storage backend
+// of settings ("memory" or "sqlite"). The SQLite database file is its DSN.
+func newRepositoryFromConfig(settings storageConfig) (CustomerRepository, error) {
+	var options []repositoryOption
+
+	if settings.UniqueEmails {
+		options = append(options, withUniqueEmails())
+	}
+
+	switch settings.Backend {
+	case "memory":
+		return newInMemoryCustomerRepository(options...), nil
+	case "sqlite":
+		return newSQLiteCustomerRepository(settings.DSN, options...)
+	default:
+		return nil, fmt.Errorf("unknown storage %q", settings.Backend)
+	}
 }
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Every response of the tests is checked against the OpenAPI
+	// document, so they fail when the routes drift from it.
+	validateResponses = true
+
+	os.Exit(m.Run())
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// inMemoryCustomerRepository keeps the customers in a slice ordered by ID.
+// Everything is lost when the process stops. It's safe for concurrent use:
+// gin serves each request on its own goroutine.
+type inMemoryCustomerRepository struct {
+	mutex     sync.RWMutex
+	customers []customer
+	// deletedVersion is the highest version of the deleted customers, so
+	// the ones created afterwards, with any ID, start after it.
+	deletedVersion uint64
+	options        repositoryOptions
+}
+
+func newInMemoryCustomerRepository(options ...repositoryOption) *inMemoryCustomerRepository {
+	return &inMemoryCustomerRepository{
+		customers: []customer{},
+		options:   newRepositoryOptions(options),
+	}
+}
+
+func (repository *inMemoryCustomerRepository) Create(ctx context.Context, newCustomer customer) (customer, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if repository.indexOf(newCustomer.ID) != -1 {
+		return customer{}, ErrConflict
+	}
+
+	if repository.isEmailTaken(newCustomer.Email, newCustomer.ID) {
+		return customer{}, ErrEmailConflict
+	}
+
+	newCustomer.Version = repository.deletedVersion + 1
+
+	// Insert the new customer at its position, to keep the slice ordered.
+	index := repository.search(newCustomer.ID)
+	repository.customers = append(repository.customers, customer{})
+	copy(repository.customers[index+1:], repository.customers[index:])
+	repository.customers[index] = newCustomer
+
+	return newCustomer, nil
+}
+
+func (repository *inMemoryCustomerRepository) Get(ctx context.Context, id string) (customer, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+
+	index := repository.indexOf(id)
+
+	if index == -1 {
+		return customer{}, ErrNotFound
+	}
+
+	return repository.customers[index], nil
+}
+
+func (repository *inMemoryCustomerRepository) List(ctx context.Context, query listQuery) (customerPage, error) {
+	positionValues, err := query.decodeCursor()
+
+	if err != nil {
+		return customerPage{}, err
+	}
+
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+
+	sortKeys := normalizeSort(query.Sort)
+	limit := query.normalizedLimit()
+
+	// The customers are already ordered by ID, so the page can be found
+	// without going through them unless they are filtered or sorted by
+	// something else.
+	if len(query.Filters) > 0 || sortKeys[0].Field != customerIdField || sortKeys[0].Descending {
+		matchingCustomers := filterCustomers(repository.customers, query.Filters)
+		selectedCustomers, hasMore := selectSortedCustomers(matchingCustomers, sortKeys, positionValues, limit)
+		page := customerPage{Customers: selectedCustomers, Total: len(matchingCustomers)}
+
+		if hasMore {
+			page.Next = query.encodeCursor(selectedCustomers[len(selectedCustomers)-1])
+		}
+
+		return page, nil
+	}
+
+	customers := repository.customers
+
+	// Skip the customers up to the one at the cursor, even if it was
+	// deleted in the meantime.
+	start := 0
+
+	if positionValues != nil {
+		start = sort.Search(len(customers), func(i int) bool {
+			return compareCustomerToPosition(customers[i], sortKeys, positionValues) > 0
+		})
+	}
+
+	end := len(customers)
+
+	if start+limit < end {
+		end = start + limit
+	}
+
+	page := customerPage{
+		// Return a copy so callers can't modify the stored customers.
+		Customers: make([]customer, end-start),
+		Total:     len(customers),
+	}
+	copy(page.Customers, customers[start:end])
+
+	if end < len(customers) {
+		page.Next = query.encodeCursor(customers[end-1])
+	}
+
+	return page, nil
+}
+
+func (repository *inMemoryCustomerRepository) Update(ctx context.Context, id string, newCustomerInformation customer, conditions ...writeCondition) (customer, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	index := repository.indexOf(id)
+
+	if index == -1 {
+		return customer{}, ErrNotFound
+	}
+
+	if !newWriteConditions(conditions).allowsVersion(repository.customers[index].Version) {
+		return customer{}, ErrVersionMismatch
+	}
+
+	if repository.isEmailTaken(newCustomerInformation.Email, id) {
+		return customer{}, ErrEmailConflict
+	}
+
+	repository.customers[index].Name = newCustomerInformation.Name
+	repository.customers[index].Surname = newCustomerInformation.Surname
+	repository.customers[index].Email = newCustomerInformation.Email
+	repository.customers[index].Birthdate = newCustomerInformation.Birthdate
+	repository.customers[index].Version++
+
+	return repository.customers[index], nil
+}
+
+func (repository *inMemoryCustomerRepository) Delete(ctx context.Context, id string, conditions ...writeCondition) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	index := repository.indexOf(id)
+
+	if index == -1 {
+		return ErrNotFound
+	}
+
+	if !newWriteConditions(conditions).allowsVersion(repository.customers[index].Version) {
+		return ErrVersionMismatch
+	}
+
+	repository.deletedVersion = max(repository.deletedVersion, repository.customers[index].Version)
+
+	auxiliaryList := make([]customer, 0, len(repository.customers)-1)
+	auxiliaryList = append(auxiliaryList, repository.customers[:index]...)
+
+	repository.customers = append(auxiliaryList, repository.customers[index+1:]...)
+
+	return nil
+}
+
+// WithinTransaction calls fn with a copy of the repository, whose changes
+// are only kept if fn returns nil. Every other call waits until it's done.
+func (repository *inMemoryCustomerRepository) WithinTransaction(ctx context.Context, fn func(transaction CustomerRepository) error) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	transaction := &inMemoryCustomerRepository{
+		customers:      append([]customer{}, repository.customers...),
+		deletedVersion: repository.deletedVersion,
+		options:        repository.options,
+	}
+
+	if err := fn(transaction); err != nil {
+		return err
+	}
+
+	repository.customers = transaction.customers
+	repository.deletedVersion = transaction.deletedVersion
+
+	return nil
+}
+
+// indexOf looks for the customer whose ID value matches the parameter. It
+// returns -1 if there's none. The caller must hold the mutex.
+func (repository *inMemoryCustomerRepository) indexOf(id string) int {
+	index := repository.search(id)
+
+	if index < len(repository.customers) && repository.customers[index].ID == id {
+		return index
+	}
+
+	return -1
+}
+
+// search returns the position of the first customer whose ID isn't lower
+// than the given one, which is where a customer with that ID belongs. The
+// caller must hold the mutex.
+func (repository *inMemoryCustomerRepository) search(id string) int {
+	return sort.Search(len(repository.customers), func(i int) bool {
+		return repository.customers[i].ID >= id
+	})
+}
+
+// isEmailTaken reports whether unique emails are enforced and a customer
+// other than the one with the given ID already uses the email. The caller
+// must hold the mutex.
+func (repository *inMemoryCustomerRepository) isEmailTaken(email string, id string) bool {
+	if !repository.options.uniqueEmails {
+		return false
+	}
+
+	normalizedEmail := normalizeEmail(email)
+
+	for _, customer := range repository.customers {
+		if customer.ID != id && normalizeEmail(customer.Email) == normalizedEmail {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filterCustomers returns a new slice with the customers that pass every
+// filter.
+func filterCustomers(customers []customer, filters []customerFilter) []customer {
+	filteredCustomers := []customer{}
+
+	for _, storedCustomer := range customers {
+		isFilteredOut := false
+
+		for _, filter := range filters {
+			if !filter.matches(storedCustomer) {
+				isFilteredOut = true
+				break
+			}
+		}
+
+		if !isFilteredOut {
+			filteredCustomers = append(filteredCustomers, storedCustomer)
+		}
+	}
+
+	return filteredCustomers
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// These tests are meant to be run with the race detector (go test -race):
+// they hammer the API concurrently to make sure the in-memory repository
+// doesn't lose writes or panic.
+
+const concurrentWorkers = 50
+
+func sendRequestForTesting(router *gin.Engine, method string, path string, body interface{}) *httptest.ResponseRecorder {
+	var requestBody bytes.Buffer
+
+	if body != nil {
+		if err := json.NewEncoder(&requestBody).Encode(body); err != nil {
+			panic(err)
+		}
+	}
+
+	request := httptest.NewRequest(method, path, &requestBody)
+	request.Header.Set("Content-Type", "application/json")
+
+	writer := httptest.NewRecorder()
+	router.ServeHTTP(writer, request)
+
+	return writer
+}
+
+func getConcurrentCustomerForTesting(worker int) customer {
+	mockedCustomer := getMockedCustomer()
+	mockedCustomer.ID = strconv.Itoa(worker + 1)
+
+	return mockedCustomer
+}
+
+func TestConcurrentPostCustomers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	var wait sync.WaitGroup
+
+	for worker := 0; worker < concurrentWorkers; worker++ {
+		wait.Add(1)
+
+		go func(worker int) {
+			defer wait.Done()
+
+			writer := sendRequestForTesting(router, "POST", "/customer", getConcurrentCustomerForTesting(worker))
+			assert.Equal(t, http.StatusCreated, writer.Code)
+
+			// Read while others are writing.
+			writer = sendRequestForTesting(router, "GET", "/customers", nil)
+			assert.Equal(t, http.StatusOK, writer.Code)
+		}(worker)
+	}
+
+	wait.Wait()
+
+	customers, err := listAllCustomers(context.Background(), repository)
+
+	assert.NoError(t, err)
+	assert.Len(t, customers, concurrentWorkers)
+	clearCustomers(nil)
+}
+
+func TestConcurrentPostCustomersWithSameId(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	var wait sync.WaitGroup
+	var created int64
+	var mutex sync.Mutex
+
+	for worker := 0; worker < concurrentWorkers; worker++ {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			writer := sendRequestForTesting(router, "POST", "/customer", getMockedCustomer())
+
+			if writer.Code == http.StatusCreated {
+				mutex.Lock()
+				created++
+				mutex.Unlock()
+			} else {
+				assert.Equal(t, http.StatusConflict, writer.Code)
+			}
+		}()
+	}
+
+	wait.Wait()
+
+	assert.Equal(t, int64(1), created)
+	clearCustomers(nil)
+}
+
+func TestConcurrentUpdateAndDeleteCustomers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	for worker := 0; worker < concurrentWorkers; worker++ {
+		writer := sendRequestForTesting(router, "POST", "/customer", getConcurrentCustomerForTesting(worker))
+		assert.Equal(t, http.StatusCreated, writer.Code)
+	}
+
+	var wait sync.WaitGroup
+
+	for worker := 0; worker < concurrentWorkers; worker++ {
+		wait.Add(1)
+
+		go func(worker int) {
+			defer wait.Done()
+
+			mockedCustomer := getConcurrentCustomerForTesting(worker)
+			path := "/customer/" + mockedCustomer.ID
+
+			mockedCustomer.Name = "Updated"
+			writer := sendRequestForTesting(router, "PUT", path, mockedCustomer)
+			assert.Equal(t, http.StatusOK, writer.Code)
+
+			writer = sendRequestForTesting(router, "GET", path, nil)
+			assert.Equal(t, http.StatusOK, writer.Code)
+
+			// Only delete half of the customers, so the ones that are
+			// left can be checked afterwards.
+			if worker%2 == 0 {
+				writer = sendRequestForTesting(router, "DELETE", path, nil)
+				assert.Equal(t, http.StatusOK, writer.Code)
+			}
+		}(worker)
+	}
+
+	wait.Wait()
+
+	customers, err := listAllCustomers(context.Background(), repository)
+
+	assert.NoError(t, err)
+	assert.Len(t, customers, concurrentWorkers/2)
+
+	for _, customer := range customers {
+		assert.Equal(t, "Updated", customer.Name)
+	}
+
+	clearCustomers(nil)
+}
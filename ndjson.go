@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ndjsonContentType is the media type of newline delimited JSON, where each
+// line is a JSON document.
+const ndjsonContentType = "application/x-ndjson"
+
+// streamCustomersAsNDJSON responds with every customer selected by the
+// filters and sort of query, one per line. The limit and cursor of query
+// are ignored.
+func streamCustomersAsNDJSON(query listQuery, context *gin.Context) {
+	context.Header("Content-Type", ndjsonContentType)
+
+	// Encode ends every customer with a new line.
+	encoder := json.NewEncoder(context.Writer)
+
+	writeCustomer := func(storedCustomer customer) error {
+		return encoder.Encode(storedCustomer)
+	}
+
+	streamMatchingCustomers(query, writeCustomer, func() error { return nil }, context)
+}
+
+// streamMatchingCustomers responds with a 200 code and writes every
+// customer selected by query with writeCustomer. What was written is sent
+// to the client after each page, so the customers are never all in memory.
+// flush must send to context.Writer what writeCustomer keeps in a buffer.
+func streamMatchingCustomers(query listQuery, writeCustomer func(customer) error, flush func() error, context *gin.Context) {
+	context.Status(http.StatusOK)
+
+	written := 0
+
+	err := forEachMatchingCustomer(context.Request.Context(), repository, query, func(storedCustomer customer) error {
+		// Each page has its own write timeout.
+		if written%maxPageLimit == 0 {
+			if err := extendWriteDeadline(context.Request.Context()); err != nil {
+				return err
+			}
+		}
+
+		if err := writeCustomer(storedCustomer); err != nil {
+			return err
+		}
+
+		if written++; written%maxPageLimit == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+
+			context.Writer.Flush()
+		}
+
+		return nil
+	})
+
+	if err == nil {
+		err = flush()
+	}
+
+	// The status code was already sent, so the error can only be logged.
+	if err != nil {
+		context.Error(err)
+	}
+}
+
+type writeDeadlineKey struct{}
+
+// withStreamingDeadlines gives the streamed responses of handler
+// writeTimeout to send each page, instead of the whole response, so big
+// exports aren't cut by the WriteTimeout of the server. The gin writer
+// can't reach the connection, so the deadline is extended through the
+// context of the request.
+func withStreamingDeadlines(handler http.Handler, writeTimeout time.Duration) http.Handler {
+	if writeTimeout <= 0 {
+		return handler
+	}
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		controller := http.NewResponseController(writer)
+
+		extendDeadline := func() error {
+			return controller.SetWriteDeadline(time.Now().Add(writeTimeout))
+		}
+
+		handler.ServeHTTP(writer, request.WithContext(context.WithValue(request.Context(), writeDeadlineKey{}, extendDeadline)))
+	})
+}
+
+// extendWriteDeadline gives the response of the request of ctx another
+// write timeout, if it was served by withStreamingDeadlines.
+func extendWriteDeadline(ctx context.Context) error {
+	if extendDeadline, isExtendable := ctx.Value(writeDeadlineKey{}).(func() error); isExtendable {
+		return extendDeadline()
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func sendNDJSONRequestForTesting(router *gin.Engine, path string, accept string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest("GET", path, nil)
+	request.Header.Set("Accept", accept)
+
+	writer := httptest.NewRecorder()
+	router.ServeHTTP(writer, request)
+
+	return writer
+}
+
+func getNDJSONCustomersForTesting(t *testing.T, writer *httptest.ResponseRecorder) []customer {
+	customers := []customer{}
+	scanner := bufio.NewScanner(writer.Body)
+
+	for scanner.Scan() {
+		var got customer
+
+		if err := json.Unmarshal(scanner.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+
+		customers = append(customers, got)
+	}
+
+	return customers
+}
+
+func TestGetCustomersAsNDJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	sendRequestForTesting(router, "POST", "/customers/bulk", getMockedCustomersForFiltering())
+
+	writer := sendNDJSONRequestForTesting(router, "/customers?surname=Wick&sort=-name&limit=1", ndjsonContentType)
+	assert.Equal(t, 200, writer.Code)
+	assert.Equal(t, ndjsonContentType, writer.Header().Get("Content-Type"))
+	assert.Empty(t, writer.Header().Get("Link"))
+
+	// Every customer is returned, regardless of the limit.
+	expected := getMockedCustomersForFiltering()
+	assert.Equal(t, []customer{expected[1], expected[2]}, getNDJSONCustomersForTesting(t, writer))
+
+	// JSON is preferred when both are accepted equally.
+	writer = sendNDJSONRequestForTesting(router, "/customers", "application/json, "+ndjsonContentType)
+	assert.Equal(t, "application/json; charset=utf-8", writer.Header().Get("Content-Type"))
+
+	writer = sendNDJSONRequestForTesting(router, "/customers?sort=phone", ndjsonContentType)
+	assert.Equal(t, 400, writer.Code)
+	assert.Equal(t, invalidQueryProblemType, getProblemFromResponse(t, writer)["type"])
+}
+
+func TestGetManyCustomersAsNDJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	count := maxPageLimit + 1
+
+	for i := 1; i <= count; i++ {
+		newCustomer := getMockedCustomer()
+		newCustomer.ID = fmt.Sprintf("%05d", i)
+		newCustomer.Email = fmt.Sprintf("customer%d@gmail.com", i)
+
+		_, err := repository.Create(context.Background(), newCustomer)
+		assert.NoError(t, err)
+	}
+
+	writer := sendNDJSONRequestForTesting(router, "/customers", ndjsonContentType)
+	assert.Equal(t, 200, writer.Code)
+	assert.True(t, writer.Flushed)
+
+	got := getNDJSONCustomersForTesting(t, writer)
+
+	assert.Len(t, got, count)
+	assert.Equal(t, "00001", got[0].ID)
+	assert.Equal(t, fmt.Sprintf("%05d", count), got[count-1].ID)
+}
+
+// slowCustomerRepository takes delay to list each page of customers.
+type slowCustomerRepository struct {
+	CustomerRepository
+	delay time.Duration
+}
+
+func (slowRepository slowCustomerRepository) List(ctx context.Context, query listQuery) (customerPage, error) {
+	time.Sleep(slowRepository.delay)
+
+	return slowRepository.CustomerRepository.List(ctx, query)
+}
+
+func TestStreamCustomersPastWriteTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+
+	defer clearCustomers(nil)
+
+	count := 3 * maxPageLimit
+
+	for i := 1; i <= count; i++ {
+		newCustomer := getMockedCustomer()
+		newCustomer.ID = fmt.Sprintf("%05d", i)
+		newCustomer.Email = fmt.Sprintf("customer%d@gmail.com", i)
+
+		_, err := repository.Create(context.Background(), newCustomer)
+		assert.NoError(t, err)
+	}
+
+	// Every page takes longer than half the write timeout, so the whole
+	// response takes longer than it.
+	writeTimeout := 200 * time.Millisecond
+	repository = slowCustomerRepository{CustomerRepository: repository, delay: writeTimeout * 3 / 4}
+
+	server := httptest.NewUnstartedServer(withStreamingDeadlines(setupRouter(), writeTimeout))
+	server.Config.WriteTimeout = writeTimeout
+	server.Start()
+	defer server.Close()
+
+	tests := []struct {
+		path   string
+		accept string
+		lines  int
+	}{
+		{"/customers/export", csvContentType, count + 1},
+		{"/customers", ndjsonContentType, count},
+	}
+
+	for _, test := range tests {
+		request, _ := http.NewRequest("GET", server.URL+test.path, nil)
+		request.Header.Set("Accept", test.accept)
+
+		response, err := server.Client().Do(request)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+
+		assert.NoError(t, err, test.path)
+		assert.Equal(t, 200, response.StatusCode, test.path)
+		assert.Equal(t, test.lines, strings.Count(string(body), "\n"), test.path)
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+)
+
+// Media types of the formats of the request and response bodies, besides
+// JSON.
+const (
+	xmlContentType     = "application/xml"
+	yamlContentType    = "application/yaml"
+	msgpackContentType = "application/msgpack"
+
+	// prettyMediaTypeParameter turns off the indentation of JSON when
+	// it's false, as in "application/json; pretty=false".
+	prettyMediaTypeParameter = "pretty"
+
+	// negotiationKey is where negotiationMiddleware keeps its result in
+	// the context.
+	negotiationKey = "negotiation"
+)
+
+// bodyFormat is a format the request and response bodies can have. Clients
+// can also name it by any of its aliases. ContentType and ProblemMediaType
+// are sent in the Content-Type header of the responses and the problems.
+type bodyFormat struct {
+	MediaType        string
+	Aliases          []string
+	ContentType      string
+	ProblemMediaType string
+	Binding          binding.Binding
+	// Render returns how data is written in this format.
+	Render func(data interface{}, isPretty bool) render.Render
+}
+
+// bodyFormats are the formats of the bodies of the API, in order of
+// preference. The first one is used when the client doesn't care.
+var bodyFormats = []bodyFormat{
+	{
+		MediaType:        gin.MIMEJSON,
+		ContentType:      "application/json; charset=utf-8",
+		ProblemMediaType: problemContentType,
+		Binding:          binding.JSON,
+		Render: func(data interface{}, isPretty bool) render.Render {
+			if isPretty {
+				return render.IndentedJSON{Data: data}
+			}
+
+			return render.JSON{Data: data}
+		},
+	},
+	{
+		MediaType:        xmlContentType,
+		Aliases:          []string{gin.MIMEXML2},
+		ContentType:      xmlContentType + "; charset=utf-8",
+		ProblemMediaType: "application/problem+xml",
+		Binding:          binding.XML,
+		Render: func(data interface{}, isPretty bool) render.Render {
+			return render.XML{Data: data}
+		},
+	},
+	{
+		MediaType:        yamlContentType,
+		Aliases:          []string{gin.MIMEYAML, "text/yaml"},
+		ContentType:      yamlContentType + "; charset=utf-8",
+		ProblemMediaType: yamlContentType,
+		Binding:          binding.YAML,
+		Render: func(data interface{}, isPretty bool) render.Render {
+			return render.YAML{Data: data}
+		},
+	},
+	{
+		MediaType:        msgpackContentType,
+		Aliases:          []string{binding.MIMEMSGPACK, "application/vnd.msgpack"},
+		ContentType:      msgpackContentType,
+		ProblemMediaType: msgpackContentType,
+		Binding:          binding.MsgPack,
+		Render: func(data interface{}, isPretty bool) render.Render {
+			return render.MsgPack{Data: data}
+		},
+	},
+}
+
+// routeMediaTypes are the media types of the responses of the routes that
+// don't respond only with bodyFormats, in order of preference.
+var routeMediaTypes = map[string][]string{
+	"/customers":        append(getBodyMediaTypes(), ndjsonContentType),
+	"/customers/export": {csvContentType},
+	"/graphql":          {gin.MIMEJSON, graphqlResponseContentType},
+	"/graphiql":         {gin.MIMEHTML},
+	"/openapi.json":     {gin.MIMEJSON},
+	"/docs":             {gin.MIMEHTML},
+}
+
+// negotiation is the result of the content negotiation of a request.
+type negotiation struct {
+	MediaType string
+	IsPretty  bool
+}
+
+// mediaRange is one of the media types of an Accept header, which may have
+// wildcards, as in "text/*" or "*/*".
+type mediaRange struct {
+	MediaType  string
+	Parameters map[string]string
+	Quality    float64
+}
+
+func getBodyMediaTypes() []string {
+	mediaTypes := []string{}
+
+	for _, format := range bodyFormats {
+		mediaTypes = append(mediaTypes, format.MediaType)
+	}
+
+	return mediaTypes
+}
+
+// findBodyFormat returns the format named by mediaType, which must be in
+// lowercase and without parameters.
+func findBodyFormat(mediaType string) (bodyFormat, bool) {
+	for _, format := range bodyFormats {
+		if format.MediaType == mediaType || containsMediaType(format.Aliases, mediaType) {
+			return format, true
+		}
+	}
+
+	return bodyFormat{}, false
+}
+
+func containsMediaType(mediaTypes []string, mediaType string) bool {
+	for _, candidate := range mediaTypes {
+		if candidate == mediaType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// negotiationMiddleware chooses the media type of the response from the
+// ones the route offers and the Accept header of the request, or responds
+// with a 406 code if none is acceptable.
+func negotiationMiddleware() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		context.Writer.Header().Add("Vary", "Accept")
+
+		_, routePath := splitApiVersion(context.FullPath())
+		offers, hasOwnMediaTypes := routeMediaTypes[routePath]
+
+		if !hasOwnMediaTypes {
+			offers = getBodyMediaTypes()
+		}
+
+		result, isAcceptable := negotiateMediaType(context.GetHeader("Accept"), offers)
+
+		if !isAcceptable {
+			notAcceptableProblem := newStatusProblem(http.StatusNotAcceptable)
+			notAcceptableProblem.Detail = "The response can only be " + strings.Join(offers, ", ")
+			abortWithProblem(notAcceptableProblem, context)
+			return
+		}
+
+		context.Set(negotiationKey, result)
+		context.Next()
+	}
+}
+
+// negotiateMediaType returns the offer with the highest quality in the
+// Accept header, as RFC 7231 says. Each offer gets the quality of the most
+// specific media range that matches it, and ties go to the first offer.
+// Clients that don't send the header accept anything.
+func negotiateMediaType(header string, offers []string) (negotiation, bool) {
+	ranges := parseAcceptHeader(header)
+	bestQuality := 0.0
+	result := negotiation{}
+
+	for _, offer := range offers {
+		matchedRange, isMatched := matchMediaRange(ranges, offer)
+
+		if isMatched && matchedRange.Quality > bestQuality {
+			bestQuality = matchedRange.Quality
+			result = negotiation{MediaType: offer, IsPretty: matchedRange.Parameters[prettyMediaTypeParameter] != "false"}
+		}
+	}
+
+	return result, bestQuality > 0
+}
+
+// matchMediaRange returns the most specific of ranges that matches offer,
+// also trying the aliases of its format.
+func matchMediaRange(ranges []mediaRange, offer string) (mediaRange, bool) {
+	names := []string{offer}
+
+	if format, isFormat := findBodyFormat(offer); isFormat {
+		names = append(names, format.Aliases...)
+	}
+
+	bestSpecificity := -1
+	var bestRange mediaRange
+
+	for _, candidate := range ranges {
+		for _, name := range names {
+			specificity := getMediaRangeSpecificity(candidate.MediaType, name)
+
+			if specificity > bestSpecificity {
+				bestSpecificity = specificity
+				bestRange = candidate
+			}
+		}
+	}
+
+	return bestRange, bestSpecificity >= 0
+}
+
+// getMediaRangeSpecificity returns how specifically mediaRange matches
+// mediaType: 2 if they're equal, 1 for "type/*", 0 for "*/*", and -1 if it
+// doesn't match.
+func getMediaRangeSpecificity(mediaRange string, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 2
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")):
+		return 1
+	case mediaRange == "*/*":
+		return 0
+	default:
+		return -1
+	}
+}
+
+// parseAcceptHeader returns the media ranges of an Accept header. Invalid
+// ranges are skipped.
+func parseAcceptHeader(header string) []mediaRange {
+	if strings.TrimSpace(header) == "" {
+		return []mediaRange{{MediaType: "*/*", Quality: 1}}
+	}
+
+	ranges := []mediaRange{}
+
+	for _, value := range strings.Split(header, ",") {
+		mediaType, parameters, err := mime.ParseMediaType(value)
+
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+
+		if qualityParameter, hasQuality := parameters["q"]; hasQuality {
+			quality, err = strconv.ParseFloat(qualityParameter, 64)
+
+			if err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{MediaType: mediaType, Parameters: parameters, Quality: quality})
+	}
+
+	return ranges
+}
+
+// getNegotiation returns what negotiationMiddleware chose for the request.
+// Requests it didn't go through get JSON.
+func getNegotiation(context *gin.Context) negotiation {
+	if result, isNegotiated := context.Get(negotiationKey); isNegotiated {
+		return result.(negotiation)
+	}
+
+	return negotiation{MediaType: gin.MIMEJSON, IsPretty: true}
+}
+
+// getResponseFormat returns the format of the response body. Routes whose
+// own media type isn't a body format, like CSV, use JSON for the rest of
+// their responses.
+func getResponseFormat(context *gin.Context) (bodyFormat, bool) {
+	result := getNegotiation(context)
+
+	if format, isFormat := findBodyFormat(result.MediaType); isFormat {
+		return format, result.IsPretty
+	}
+
+	return bodyFormats[0], true
+}
+
+// respond writes data as the body of the response, in the negotiated
+// format.
+func respond(status int, data interface{}, context *gin.Context) {
+	format, isPretty := getResponseFormat(context)
+
+	context.Header("Content-Type", format.ContentType)
+	context.Render(status, format.Render(data, isPretty))
+}
+
+// bindRequestBody binds the request body to obj, reading it in the format
+// of its Content-Type. Requests without a Content-Type are read as JSON.
+// If the body can't be bound, it responds with the problem found.
+func bindRequestBody(obj interface{}, context *gin.Context) bool {
+	format, isSupported := getRequestFormat(context)
+
+	if !isSupported {
+		abortWithUnsupportedMediaType(context)
+		return false
+	}
+
+	if err := context.ShouldBindWith(obj, format.Binding); err != nil {
+		abortWithProblem(newInvalidBodyProblem(err), context)
+		return false
+	}
+
+	return true
+}
+
+func getRequestFormat(context *gin.Context) (bodyFormat, bool) {
+	contentType := strings.ToLower(context.ContentType())
+
+	if contentType == "" {
+		return bodyFormats[0], true
+	}
+
+	return findBodyFormat(contentType)
+}
+
+func abortWithUnsupportedMediaType(context *gin.Context) {
+	mediaTypes := []string{}
+
+	for _, format := range bodyFormats {
+		mediaTypes = append(mediaTypes, format.MediaType)
+		mediaTypes = append(mediaTypes, format.Aliases...)
+	}
+
+	sort.Strings(mediaTypes)
+
+	unsupportedProblem := newStatusProblem(http.StatusUnsupportedMediaType)
+	unsupportedProblem.Detail = fmt.Sprintf("Content-Type must be one of %s", strings.Join(mediaTypes, ", "))
+	abortWithProblem(unsupportedProblem, context)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+	"gopkg.in/yaml.v2"
+)
+
+func sendNegotiatedRequestForTesting(router *gin.Engine, method string, path string, contentType string, accept string, body []byte) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, bytes.NewReader(body))
+	request.Header.Set("Content-Type", contentType)
+	request.Header.Set("Accept", accept)
+
+	writer := httptest.NewRecorder()
+	router.ServeHTTP(writer, request)
+
+	return writer
+}
+
+func encodeMsgPackForTesting(t *testing.T, data interface{}) []byte {
+	var encoded bytes.Buffer
+
+	if err := codec.NewEncoder(&encoded, new(codec.MsgpackHandle)).Encode(data); err != nil {
+		t.Fatal(err)
+	}
+
+	return encoded.Bytes()
+}
+
+func TestNegotiateMediaType(t *testing.T) {
+	offers := getBodyMediaTypes()
+
+	tests := []struct {
+		header           string
+		expected         string
+		expectedIsPretty bool
+	}{
+		{"", gin.MIMEJSON, true},
+		{"*/*", gin.MIMEJSON, true},
+		{"application/json; pretty=false", gin.MIMEJSON, false},
+		{"text/xml", xmlContentType, true},
+		{"application/x-yaml, application/json;q=0.5", yamlContentType, true},
+		{"application/*;q=0.2, application/msgpack", msgpackContentType, true},
+		// The most specific range wins, so JSON isn't acceptable.
+		{"application/json;q=0, */*;q=0.1", xmlContentType, true},
+		{"text/html, not a media type", "", false},
+	}
+
+	for _, test := range tests {
+		got, isAcceptable := negotiateMediaType(test.header, offers)
+
+		assert.Equal(t, test.expected != "", isAcceptable, test.header)
+		assert.Equal(t, test.expected, got.MediaType, test.header)
+
+		if isAcceptable {
+			assert.Equal(t, test.expectedIsPretty, got.IsPretty, test.header)
+		}
+	}
+}
+
+func TestCustomerInEveryFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	xmlCustomer, _ := xml.Marshal(getMockedCustomer())
+	yamlCustomer, _ := yaml.Marshal(getMockedCustomer())
+
+	tests := []struct {
+		mediaType string
+		body      []byte
+		decode    func([]byte, interface{}) error
+	}{
+		{xmlContentType, xmlCustomer, xml.Unmarshal},
+		{yamlContentType, yamlCustomer, yaml.Unmarshal},
+		{msgpackContentType, encodeMsgPackForTesting(t, getMockedCustomer()), func(data []byte, v interface{}) error {
+			return codec.NewDecoderBytes(data, new(codec.MsgpackHandle)).Decode(v)
+		}},
+	}
+
+	for _, test := range tests {
+		writer := sendNegotiatedRequestForTesting(router, "POST", "/customer", test.mediaType, test.mediaType, test.body)
+		assert.Equal(t, 201, writer.Code, test.mediaType)
+		assert.True(t, strings.HasPrefix(writer.Header().Get("Content-Type"), test.mediaType), test.mediaType)
+
+		// The version is only sent in the ETag.
+		assert.NotContains(t, strings.ToLower(writer.Body.String()), "version", test.mediaType)
+
+		var got customer
+		assert.NoError(t, test.decode(writer.Body.Bytes(), &got), test.mediaType)
+		assert.Equal(t, getMockedCustomer(), got, test.mediaType)
+
+		writer = sendNegotiatedRequestForTesting(router, "DELETE", "/customer/1", "", test.mediaType, nil)
+		assert.Equal(t, 200, writer.Code, test.mediaType)
+	}
+}
+
+func TestResponsesInXML(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	searchableRepository, err := newSearchableCustomerRepository(context.Background(), newInMemoryCustomerRepository())
+	assert.NoError(t, err)
+
+	repository = searchableRepository
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	body := []byte("<customers><customer><id>1</id><name>Augusto</name><surname>Giavedoni</surname>" +
+		"<email>augusto.giavedoni@gmail.com</email><birthdate>2000-02-20</birthdate></customer>" +
+		"<customer><id>2</id></customer></customers>")
+
+	writer := sendNegotiatedRequestForTesting(router, "POST", "/customers/bulk", xmlContentType, xmlContentType, body)
+	assert.Equal(t, 207, writer.Code)
+
+	var bulk bulkResponse
+	assert.NoError(t, xml.Unmarshal(writer.Body.Bytes(), &bulk))
+	assert.Equal(t, []int{201, 400}, getBulkStatuses(bulk.Results))
+	assert.Len(t, bulk.Results[1].Problem.Errors, 4)
+
+	writer = sendNegotiatedRequestForTesting(router, "GET", "/customers", "", xmlContentType, nil)
+	assert.Equal(t, 200, writer.Code)
+
+	var list customerListResponse
+	assert.NoError(t, xml.Unmarshal(writer.Body.Bytes(), &list))
+	assert.Equal(t, []customer{getMockedCustomer()}, list.Data)
+	assert.Equal(t, 1, list.Total)
+
+	writer = sendNegotiatedRequestForTesting(router, "GET", "/customers/search?q=giavedoni", "", xmlContentType, nil)
+	assert.Equal(t, 200, writer.Code)
+	assert.Contains(t, writer.Body.String(), "<highlights><email>augusto.&lt;em&gt;giavedoni&lt;/em&gt;@gmail.com</email>")
+
+	writer = sendNegotiatedRequestForTesting(router, "GET", "/customer/2", "", xmlContentType, nil)
+	assert.Equal(t, 404, writer.Code)
+	assert.Equal(t, "application/problem+xml", writer.Header().Get("Content-Type"))
+	assert.Contains(t, writer.Body.String(), `<problem xmlns="urn:ietf:rfc:7807">`)
+}
+
+func TestUnsupportedMediaTypes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	writer := sendNegotiatedRequestForTesting(router, "POST", "/customer", "text/plain", "", []byte("Augusto"))
+	assert.Equal(t, 415, writer.Code)
+	assert.Equal(t, problemContentType, writer.Header().Get("Content-Type"))
+
+	writer = sendNegotiatedRequestForTesting(router, "GET", "/customers", "", "text/html", nil)
+	assert.Equal(t, 406, writer.Code)
+	assert.Equal(t, "Not Acceptable", getProblemFromResponse(t, writer)["title"])
+
+	// Each route offers its own media types.
+	writer = sendNegotiatedRequestForTesting(router, "GET", "/customers/export", "", csvContentType, nil)
+	assert.Equal(t, 200, writer.Code)
+
+	writer = sendNegotiatedRequestForTesting(router, "GET", "/customers/export", "", gin.MIMEJSON, nil)
+	assert.Equal(t, 406, writer.Code)
+}
+
+func TestCompactJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	sendRequestForTesting(router, "POST", "/customer", getMockedCustomer())
+
+	writer := sendNegotiatedRequestForTesting(router, "GET", "/customer/1", "", "application/json; pretty=false", nil)
+	assert.Equal(t, 200, writer.Code)
+	assert.Equal(t, `{"id":"1","name":"Augusto","surname":"Giavedoni","email":"augusto.giavedoni@gmail.com","birthdate":"2000-02-20"}`,
+		writer.Body.String())
+
+	writer = sendNegotiatedRequestForTesting(router, "GET", "/customer/1", "", gin.MIMEJSON, nil)
+	assert.Contains(t, writer.Body.String(), "\n    \"id\": \"1\"")
+}
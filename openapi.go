@@ -0,0 +1,62 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openapiDocument describes every route of the API with OpenAPI 3.1. It
+// must be updated along with the routes of setupRouter.
+//
+//go:embed openapi.json
+var openapiDocument []byte
+
+// ginPathParameter matches the parameters of a gin path, like :id, which
+// OpenAPI writes as {id}.
+var ginPathParameter = regexp.MustCompile(`[:*]([^/]+)`)
+
+// openapiDocumentVersion is the version of the API described by
+// openapiDocument. Its routes are written without the prefix, since they're
+// also served without one.
+const openapiDocumentVersion = "v1"
+
+// toOpenapiPath returns how openapiDocument writes the gin path.
+func toOpenapiPath(ginPath string) string {
+	if versionPrefix, routePath := splitApiVersion(ginPath); versionPrefix == "/"+openapiDocumentVersion {
+		ginPath = routePath
+	}
+
+	return ginPathParameter.ReplaceAllString(ginPath, "{$1}")
+}
+
+// getOpenapiDocument serves openapiDocument as it's written.
+func getOpenapiDocument(context *gin.Context) {
+	context.Data(http.StatusOK, "application/json; charset=utf-8", openapiDocument)
+}
+
+// getSwaggerUi serves Swagger UI, which shows openapiDocument and lets
+// clients try the routes from the browser.
+func getSwaggerUi(context *gin.Context) {
+	context.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUiPage))
+}
+
+// swaggerUiPage loads Swagger UI from a CDN and points it to /openapi.json.
+const swaggerUiPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<title>Customers API - Swagger UI</title>
+	<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+	<div id="swagger-ui"></div>
+	<script crossorigin src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+	<script>
+		window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+	</script>
+</body>
+</html>
+`
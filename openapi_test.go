@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenapiDocumentHasEveryRoute(t *testing.T) {
+	// Routes only registered in debug mode must be documented too.
+	gin.SetMode(gin.DebugMode)
+	defer gin.SetMode(gin.TestMode)
+
+	var document struct {
+		Openapi string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+
+	if err := json.Unmarshal(openapiDocument, &document); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "3.1.0", document.Openapi)
+
+	for _, route := range setupRouter().Routes() {
+		path := toOpenapiPath(route.Path)
+		_, isDocumented := document.Paths[path][strings.ToLower(route.Method)]
+
+		assert.True(t, isDocumented, "%s %s is missing from openapi.json", route.Method, path)
+	}
+}
+
+func TestOpenapiServersAreRelative(t *testing.T) {
+	type server struct {
+		URL string `json:"url"`
+	}
+
+	var document struct {
+		Servers []server `json:"servers"`
+		Paths   map[string]struct {
+			Servers []server `json:"servers"`
+		} `json:"paths"`
+	}
+
+	if err := json.Unmarshal(openapiDocument, &document); err != nil {
+		t.Fatal(err)
+	}
+
+	servers := document.Servers
+
+	for _, pathItem := range document.Paths {
+		servers = append(servers, pathItem.Servers...)
+	}
+
+	// Relative URLs point to the host the document was read from, so it
+	// can be tried wherever the API is deployed.
+	for _, documentServer := range servers {
+		assert.True(t, strings.HasPrefix(documentServer.URL, "/"), documentServer.URL)
+	}
+}
+
+func TestGetOpenapiDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := setupRouter()
+
+	writer := sendRequestForTesting(router, "GET", "/openapi.json", nil)
+	assert.Equal(t, 200, writer.Code)
+	assert.Equal(t, "application/json; charset=utf-8", writer.Header().Get("Content-Type"))
+	assert.JSONEq(t, string(openapiDocument), writer.Body.String())
+
+	writer = sendRequestForTesting(router, "GET", "/docs", nil)
+	assert.Equal(t, 200, writer.Code)
+	assert.Contains(t, writer.Body.String(), `url: "/openapi.json"`)
+}
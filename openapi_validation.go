@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+)
+
+// validateRequests makes the API check every request against the OpenAPI
+// document before its handler runs. It's replaced on startup by the
+// configured validate_requests.
+var validateRequests = false
+
+// validateResponses makes the API check every response against the
+// OpenAPI document, replacing the ones that drifted from it with a 500
+// problem. Only the tests set it, so they fail when the routes drift from
+// the document.
+var validateResponses = false
+
+// loadOpenapiDocument parses openapiDocument once. The document isn't
+// validated as a whole, since kin-openapi only validates OpenAPI 3.0
+// documents, but the parts of 3.1 it uses are checked as expected.
+var loadOpenapiDocument = sync.OnceValues(func() (*openapi3.T, error) {
+	return openapi3.NewLoader().LoadFromData(openapiDocument)
+})
+
+func init() {
+	// Errors are sent to clients, so they shouldn't have the whole schema.
+	openapi3.SchemaErrorDetailsDisabled = true
+
+	// Bodies of these media types are JSON too.
+	openapi3filter.RegisterBodyDecoder(mergePatchContentType, openapi3filter.JSONBodyDecoder)
+	openapi3filter.RegisterBodyDecoder(graphqlResponseContentType, openapi3filter.JSONBodyDecoder)
+}
+
+// openapiValidationMiddleware checks the requests, the responses or both
+// against the operation of openapiDocument that matches their route.
+// Invalid requests are responded with the same problems the handlers use,
+// and invalid responses are replaced by a 500 problem, so tests catch the
+// routes that drifted from the document. Bodies are only checked when
+// they're JSON, YAML or CSV, and streamed responses aren't checked.
+func openapiValidationMiddleware(isRequestValidated bool, isResponseValidated bool) gin.HandlerFunc {
+	document, err := loadOpenapiDocument()
+
+	if err != nil {
+		panic(err)
+	}
+
+	return func(context *gin.Context) {
+		route, isDocumented := findOpenapiRoute(document, context)
+
+		if !isDocumented {
+			context.Next()
+			return
+		}
+
+		requestInput := &openapi3filter.RequestValidationInput{
+			Request:    context.Request,
+			PathParams: map[string]string{},
+			Route:      route,
+			Options: &openapi3filter.Options{
+				MultiError:          true,
+				SkipSettingDefaults: true,
+				AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+			},
+		}
+
+		for _, parameter := range context.Params {
+			requestInput.PathParams[parameter.Key] = parameter.Value
+		}
+
+		if isRequestValidated && !validateOpenapiRequest(requestInput, context) {
+			return
+		}
+
+		if negotiatedType := getNegotiation(context).MediaType; !isResponseValidated ||
+			negotiatedType == ndjsonContentType || negotiatedType == csvContentType {
+			context.Next()
+			return
+		}
+
+		writer := &bufferedResponseWriter{ResponseWriter: context.Writer}
+		context.Writer = writer
+
+		context.Next()
+
+		context.Writer = writer.ResponseWriter
+
+		err := openapi3filter.ValidateResponse(context.Request.Context(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: requestInput,
+			Status:                 writer.Status(),
+			Header:                 writer.Header(),
+			Body:                   io.NopCloser(bytes.NewReader(writer.body.Bytes())),
+			Options: &openapi3filter.Options{
+				MultiError:          true,
+				ExcludeResponseBody: !isOpenapiBodyDecoded(writer.Header().Get("Content-Type")),
+			},
+		})
+
+		if err != nil {
+			context.Error(err)
+
+			invalidResponseProblem := newStatusProblem(http.StatusInternalServerError)
+			invalidResponseProblem.Detail = "The response doesn't match the OpenAPI document: " + err.Error()
+			abortWithProblem(invalidResponseProblem, context)
+
+			return
+		}
+
+		if writer.body.Len() > 0 {
+			writer.ResponseWriter.Write(writer.body.Bytes())
+		}
+	}
+}
+
+// findOpenapiRoute returns the operation of document that matches the
+// route of the request, if it's documented.
+func findOpenapiRoute(document *openapi3.T, context *gin.Context) (*routers.Route, bool) {
+	path := toOpenapiPath(context.FullPath())
+	pathItem := document.Paths.Value(path)
+
+	if pathItem == nil {
+		return nil, false
+	}
+
+	operation := pathItem.GetOperation(context.Request.Method)
+
+	if operation == nil {
+		return nil, false
+	}
+
+	return &routers.Route{Spec: document, Path: path, PathItem: pathItem, Method: context.Request.Method, Operation: operation}, true
+}
+
+// validateOpenapiRequest responds with a problem if the request doesn't
+// match its operation. Requests without a Content-Type are read as JSON,
+// as bindRequestBody does.
+func validateOpenapiRequest(input *openapi3filter.RequestValidationInput, context *gin.Context) bool {
+	if context.Request.Header.Get("Content-Type") == "" {
+		input.Request = context.Request.Clone(context.Request.Context())
+		input.Request.Header.Set("Content-Type", gin.MIMEJSON)
+	}
+
+	requestBody := input.Route.Operation.RequestBody
+	mediaType, _, _ := mime.ParseMediaType(input.Request.Header.Get("Content-Type"))
+
+	// Bodies the operation doesn't accept are left for the handlers, which
+	// respond with 415 Unsupported Media Type. CSV files are also left for
+	// importCustomers, which checks them as it reads them, so they're never
+	// read whole into memory.
+	input.Options.ExcludeRequestBody = requestBody == nil || requestBody.Value.GetMediaType(mediaType) == nil ||
+		!isOpenapiBodyDecoded(mediaType) || mediaType == csvContentType
+
+	err := openapi3filter.ValidateRequest(context.Request.Context(), input)
+
+	// The body was read, so give the handler the copy that was left.
+	context.Request.Body = input.Request.Body
+
+	if err != nil {
+		abortWithProblem(newOpenapiRequestProblem(err), context)
+		return false
+	}
+
+	return true
+}
+
+// isOpenapiBodyDecoded reports whether kin-openapi can read bodies of
+// contentType. XML and MessagePack can't be checked.
+func isOpenapiBodyDecoded(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	return err == nil && openapi3filter.RegisteredBodyDecoder(mediaType) != nil
+}
+
+// newOpenapiRequestProblem translates the errors found by kin-openapi into
+// the problem the handlers would have returned: an invalid ID comes first,
+// then the query parameters and the body.
+func newOpenapiRequestProblem(err error) *problem {
+	queryErrors := []fieldError{}
+	bodyErrors := []fieldError{}
+	var bodyProblem *problem
+
+	for _, requestError := range unwrapOpenapiErrors[*openapi3filter.RequestError](err) {
+		switch {
+		case requestError.Parameter != nil && requestError.Parameter.In == openapi3.ParameterInPath:
+			return newProblem(http.StatusBadRequest, invalidIdProblemType, "ID is not valid", "")
+		case requestError.Parameter != nil:
+			queryErrors = append(queryErrors, newOpenapiFieldErrors(requestError.Parameter.Name, requestError.Err)...)
+		case errors.Is(requestError.Err, openapi3filter.ErrInvalidRequired):
+			bodyProblem = newInvalidBodyProblem(errors.New("the request body is missing"))
+		case len(unwrapOpenapiErrors[*openapi3.SchemaError](requestError.Err)) > 0:
+			for _, fieldError := range newOpenapiFieldErrors("", requestError.Err) {
+				// The body as a whole, like an empty array, is wrong.
+				if fieldError.Field == "" {
+					bodyProblem = newInvalidBodyProblem(errors.New(fieldError.Message))
+				} else {
+					bodyErrors = append(bodyErrors, fieldError)
+				}
+			}
+		default:
+			bodyProblem = newInvalidBodyProblem(requestError.Err)
+		}
+	}
+
+	switch {
+	case len(queryErrors) > 0:
+		return newInvalidQueryProblem(queryErrors)
+	case bodyProblem != nil:
+		return bodyProblem
+	case len(bodyErrors) > 0:
+		return newValidationProblem(bodyErrors)
+	default:
+		return newInvalidBodyProblem(err)
+	}
+}
+
+// newOpenapiFieldErrors returns a fieldError for each value of err that
+// didn't match its schema. The field is the path of the value, as in
+// "email" or "0.email", or name for the value itself.
+func newOpenapiFieldErrors(name string, err error) []fieldError {
+	fieldErrors := []fieldError{}
+
+	for _, schemaError := range unwrapOpenapiErrors[*openapi3.SchemaError](err) {
+		field := strings.Join(schemaError.JSONPointer(), ".")
+		code := invalidFieldErrorCode
+
+		if field == "" {
+			field = name
+		}
+
+		if schemaError.SchemaField == "required" {
+			code = requiredFieldErrorCode
+		}
+
+		fieldErrors = append(fieldErrors, fieldError{field, code, capitalize(schemaError.Reason)})
+	}
+
+	if len(fieldErrors) == 0 {
+		fieldErrors = append(fieldErrors, fieldError{name, invalidFieldErrorCode, capitalize(err.Error())})
+	}
+
+	return fieldErrors
+}
+
+// unwrapOpenapiErrors returns every error of type T in err, looking into
+// the openapi3.MultiError that kin-openapi returns when it finds more than
+// one.
+func unwrapOpenapiErrors[T error](err error) []T {
+	// errors.As would also find the MultiError wrapped by a RequestError.
+	if multiError, isMultiError := err.(openapi3.MultiError); isMultiError {
+		found := []T{}
+
+		for _, wrappedError := range multiError {
+			found = append(found, unwrapOpenapiErrors[T](wrappedError)...)
+		}
+
+		return found
+	}
+
+	var target T
+
+	if errors.As(err, &target) {
+		return []T{target}
+	}
+
+	return nil
+}
+
+func capitalize(message string) string {
+	if message == "" {
+		return message
+	}
+
+	first, size := utf8.DecodeRuneInString(message)
+
+	return string(unicode.ToUpper(first)) + message[size:]
+}
+
+// bufferedResponseWriter keeps the body of the response until it's
+// validated, so a different one can be sent instead.
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (writer *bufferedResponseWriter) Write(data []byte) (int, error) {
+	return writer.body.Write(data)
+}
+
+func (writer *bufferedResponseWriter) WriteString(data string) (int, error) {
+	return writer.body.WriteString(data)
+}
+
+func (writer *bufferedResponseWriter) Written() bool {
+	return writer.body.Len() > 0 || writer.ResponseWriter.Written()
+}
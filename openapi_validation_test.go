@@ -0,0 +1,157 @@
+package main
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func getValidatingRouterForTesting(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	validateRequests = true
+	repository = newInMemoryCustomerRepository()
+
+	t.Cleanup(func() {
+		validateRequests = false
+		clearCustomers(nil)
+	})
+
+	return setupRouter()
+}
+
+func TestValidateRequestBodies(t *testing.T) {
+	router := getValidatingRouterForTesting(t)
+
+	writer := sendRequestForTesting(router, "POST", "/customer", map[string]interface{}{"name": 1, "surname": "", "email": "john.wick@gmail.com"})
+	assert.Equal(t, 400, writer.Code)
+
+	got := getProblemFromResponse(t, writer)
+	assert.Equal(t, validationProblemType, got["type"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"field": "name", "code": "invalid", "message": "Value must be a string"},
+		map[string]interface{}{"field": "surname", "code": "invalid", "message": "Minimum string length is 1"},
+		map[string]interface{}{"field": "birthdate", "code": "required", "message": `Property "birthdate" is missing`},
+	}, got["errors"])
+
+	writer = sendRequestForTesting(router, "POST", "/customers/bulk", []interface{}{getMockedCustomer(), map[string]interface{}{"id": "2"}})
+	assert.Equal(t, 400, writer.Code)
+	assert.Len(t, getProblemFromResponse(t, writer)["errors"], 4)
+
+	writer = sendRequestForTesting(router, "POST", "/customers/bulk", []customer{})
+	assert.Equal(t, 400, writer.Code)
+	assert.Equal(t, invalidBodyProblemType, getProblemFromResponse(t, writer)["type"])
+
+	writer = sendRequestForTesting(router, "DELETE", "/customers/bulk", []interface{}{"1", map[string]interface{}{"id": "2", "version": 0}})
+	assert.Equal(t, 400, writer.Code)
+	assert.Equal(t, validationProblemType, getProblemFromResponse(t, writer)["type"])
+
+	writer = sendRequestForTesting(router, "DELETE", "/customers/bulk", []interface{}{"1", map[string]interface{}{"id": "2", "version": 1}})
+	assert.Equal(t, 207, writer.Code)
+
+	// Valid bodies reach the handler, even without a Content-Type.
+	request := httptest.NewRequest("POST", "/customer", strings.NewReader(`{"id": "1", "name": "Augusto", "surname": "Giavedoni",
+		"email": "augusto.giavedoni@gmail.com", "birthdate": "2000-02-20"}`))
+	writer = httptest.NewRecorder()
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, 201, writer.Code)
+	assert.JSONEq(t, `{"id": "1", "name": "Augusto", "surname": "Giavedoni",
+		"email": "augusto.giavedoni@gmail.com", "birthdate": "2000-02-20"}`, writer.Body.String())
+
+	// XML can't be checked, so it's left for the handler.
+	request = httptest.NewRequest("PUT", "/customer/1", strings.NewReader(`<customer><id>1</id><name>John</name>
+		<surname>Wick</surname><email>john.wick@gmail.com</email><birthdate>1964-09-02</birthdate></customer>`))
+	request.Header.Set("Content-Type", xmlContentType)
+	writer = httptest.NewRecorder()
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, 200, writer.Code)
+}
+
+func TestValidateRequestParameters(t *testing.T) {
+	router := getValidatingRouterForTesting(t)
+
+	writer := sendRequestForTesting(router, "GET", "/customers?limit=0&birthdate[gt]=yesterday", nil)
+	assert.Equal(t, 400, writer.Code)
+
+	got := getProblemFromResponse(t, writer)
+	assert.Equal(t, invalidQueryProblemType, got["type"])
+
+	fields := []string{}
+
+	for _, fieldError := range got["errors"].([]interface{}) {
+		fields = append(fields, fieldError.(map[string]interface{})["field"].(string))
+	}
+
+	assert.ElementsMatch(t, []string{"limit", "birthdate[gt]"}, fields)
+
+	writer = sendRequestForTesting(router, "DELETE", "/customers/bulk?dry_run=maybe", []string{"1"})
+	assert.Equal(t, 400, writer.Code)
+	assert.Equal(t, invalidQueryProblemType, getProblemFromResponse(t, writer)["type"])
+
+	writer = sendRequestForTesting(router, "GET", "/customer/"+strings.Repeat("1", 65), nil)
+	assert.Equal(t, 400, writer.Code)
+	assert.Equal(t, invalidIdProblemType, getProblemFromResponse(t, writer)["type"])
+
+	writer = sendRequestForTesting(router, "GET", "/customers?limit=10&sort=-name", nil)
+	assert.Equal(t, 200, writer.Code)
+}
+
+// countingReader counts the bytes read from Reader.
+type countingReader struct {
+	io.Reader
+	count int
+}
+
+func (reader *countingReader) Read(data []byte) (int, error) {
+	n, err := reader.Reader.Read(data)
+	reader.count += n
+
+	return n, err
+}
+
+func TestValidateRequestsLeavesCSVFilesToImport(t *testing.T) {
+	router := getValidatingRouterForTesting(t)
+
+	body := &countingReader{Reader: io.MultiReader(strings.NewReader("id,name,surname,email,birthdate\n1,"),
+		strings.NewReader(strings.Repeat("A", 2*maxImportedBytes)))}
+
+	request := httptest.NewRequest("POST", "/customers/import", body)
+	request.Header.Set("Content-Type", csvContentType)
+
+	writer := httptest.NewRecorder()
+	router.ServeHTTP(writer, request)
+
+	// The file stops being read once it's too large.
+	assert.Equal(t, 413, writer.Code)
+	assert.Less(t, body.count, maxImportedBytes+64*1024)
+}
+
+func TestValidateResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(problemMiddleware(), negotiationMiddleware(), openapiValidationMiddleware(false, true))
+
+	// A customer without most of its fields doesn't match the document.
+	router.GET("/customer/:id", func(context *gin.Context) {
+		respond(200, gin.H{"id": context.Param("id")}, context)
+	})
+
+	writer := sendRequestForTesting(router, "GET", "/customer/1", nil)
+	assert.Equal(t, 500, writer.Code)
+	assert.Equal(t, problemContentType, writer.Header().Get("Content-Type"))
+	assert.Contains(t, getProblemFromResponse(t, writer)["detail"], `property "name" is missing`)
+
+	// Formats that can't be checked are sent as they are.
+	request := httptest.NewRequest("GET", "/customer/1", nil)
+	request.Header.Set("Accept", xmlContentType)
+	writer = httptest.NewRecorder()
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, 200, writer.Code)
+	assert.Contains(t, writer.Body.String(), "<id>1</id>")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultPageLimit is the number of customers of a page when the
+	// client doesn't ask for a different one.
+	defaultPageLimit = 100
+	// maxPageLimit is the highest number of customers of a page.
+	maxPageLimit = 1000
+)
+
+// customerListResponse is the envelope of the customers returned by
+// GET /customers. Next is the cursor of the following page, or null if
+// this is the last one.
+type customerListResponse struct {
+	XMLName xml.Name   `json:"-" xml:"customers" yaml:"-"`
+	Data    []customer `json:"data" xml:"data>customer" yaml:"data"`
+	Next    *string    `json:"next" xml:"next,omitempty" yaml:"next"`
+	Total   int        `json:"total" xml:"total" yaml:"total"`
+}
+
+func newCustomerListResponse(page customerPage) customerListResponse {
+	response := customerListResponse{Data: page.Customers, Total: page.Total}
+
+	if page.Next != "" {
+		response.Next = &page.Next
+	}
+
+	return response
+}
+
+// parseListQuery reads the limit, cursor, sort and filter query
+// parameters, returning every problem found with them.
+func parseListQuery(parameters url.Values) (listQuery, []fieldError) {
+	query := listQuery{Limit: defaultPageLimit, Cursor: parameters.Get(listCursorParameter)}
+	fieldErrors := []fieldError{}
+
+	if limitParameter := parameters.Get(listLimitParameter); limitParameter != "" {
+		limit, err := strconv.Atoi(limitParameter)
+
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			fieldErrors = append(fieldErrors, fieldError{listLimitParameter, invalidFieldErrorCode,
+				fmt.Sprintf("Limit must be a number between 1 and %d", maxPageLimit)})
+		} else {
+			query.Limit = limit
+		}
+	}
+
+	if query.Cursor != "" {
+		if _, err := decodeCursor(query.Cursor); err != nil {
+			fieldErrors = append(fieldErrors, newInvalidCursorFieldError())
+		}
+	}
+
+	sortKeys, sortErrors := parseSort(parameters)
+	query.Sort = sortKeys
+	fieldErrors = append(fieldErrors, sortErrors...)
+
+	filters, filterErrors := parseFilters(parameters)
+	query.Filters = filters
+	fieldErrors = append(fieldErrors, filterErrors...)
+
+	return query, fieldErrors
+}
+
+func newInvalidCursorFieldError() fieldError {
+	return fieldError{listCursorParameter, invalidFieldErrorCode, "Cursor is not valid"}
+}
+
+// setPaginationLinks adds a Link header (RFC 8288) with the first and, if
+// there's one, the next page of the list. Other query parameters are kept.
+func setPaginationLinks(page customerPage, context *gin.Context) {
+	links := []string{formatPageLink(context.Request.URL, "", "first")}
+
+	if page.Next != "" {
+		links = append(links, formatPageLink(context.Request.URL, page.Next, "next"))
+	}
+
+	context.Header("Link", strings.Join(links, ", "))
+}
+
+func formatPageLink(requestUrl *url.URL, encodedCursor string, relation string) string {
+	parameters := requestUrl.Query()
+
+	if encodedCursor == "" {
+		parameters.Del("cursor")
+	} else {
+		parameters.Set("cursor", encodedCursor)
+	}
+
+	pageUrl := url.URL{Path: requestUrl.Path, RawQuery: parameters.Encode()}
+
+	return fmt.Sprintf("<%s>; rel=%q", pageUrl.String(), relation)
+}
+
+func newInvalidQueryProblem(fieldErrors []fieldError) *problem {
+	invalidQueryProblem := newProblem(http.StatusBadRequest, invalidQueryProblemType, "Query parameters are not valid", "")
+	invalidQueryProblem.Errors = fieldErrors
+
+	return invalidQueryProblem
+}
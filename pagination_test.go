@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type customerListResponseForTesting struct {
+	Data  []customer `json:"data"`
+	Next  *string    `json:"next"`
+	Total int        `json:"total"`
+}
+
+func getCustomerListFromResponse(t *testing.T, body []byte) customerListResponseForTesting {
+	var got customerListResponseForTesting
+
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	return got
+}
+
+func postNumberedCustomersForTesting(t *testing.T, router *gin.Engine, count int) {
+	for i := 1; i <= count; i++ {
+		mockedCustomer := getMockedCustomer()
+		mockedCustomer.ID = "customer-" + strconv.Itoa(i)
+
+		writer := sendRequestForTesting(router, "POST", "/customer", mockedCustomer)
+		assert.Equal(t, 201, writer.Code)
+	}
+}
+
+func TestGetCustomersWalksEveryPage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	postNumberedCustomersForTesting(t, router, 5)
+
+	writer := sendRequestForTesting(router, "GET", "/customers?limit=2", nil)
+	assert.Equal(t, 200, writer.Code)
+
+	got := getCustomerListFromResponse(t, writer.Body.Bytes())
+
+	assert.Equal(t, []string{"customer-1", "customer-2"}, getCustomerIds(got.Data))
+	assert.Equal(t, 5, got.Total)
+	assert.NotNil(t, got.Next)
+	assert.Equal(t,
+		`</customers?limit=2>; rel="first", </customers?cursor=`+*got.Next+`&limit=2>; rel="next"`,
+		writer.Header().Get("Link"))
+
+	writer = sendRequestForTesting(router, "GET", "/customers?limit=2&cursor="+*got.Next, nil)
+	got = getCustomerListFromResponse(t, writer.Body.Bytes())
+
+	assert.Equal(t, []string{"customer-3", "customer-4"}, getCustomerIds(got.Data))
+	assert.NotNil(t, got.Next)
+
+	// Removing the last customer of the page doesn't break its cursor.
+	writer = sendRequestForTesting(router, "DELETE", "/customer/customer-4", nil)
+	assert.Equal(t, 200, writer.Code)
+
+	writer = sendRequestForTesting(router, "GET", "/customers?limit=2&cursor="+*got.Next, nil)
+	got = getCustomerListFromResponse(t, writer.Body.Bytes())
+
+	assert.Equal(t, []string{"customer-5"}, getCustomerIds(got.Data))
+	assert.Equal(t, 4, got.Total)
+	assert.Nil(t, got.Next)
+	assert.Equal(t, `</customers?limit=2>; rel="first"`, writer.Header().Get("Link"))
+}
+
+func TestGetCustomersWithInvalidQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	tests := []struct {
+		query    string
+		expected []fieldError
+	}{
+		{"limit=0", []fieldError{{"limit", invalidFieldErrorCode, "Limit must be a number between 1 and 1000"}}},
+		{"limit=1001", []fieldError{{"limit", invalidFieldErrorCode, "Limit must be a number between 1 and 1000"}}},
+		{"limit=ten", []fieldError{{"limit", invalidFieldErrorCode, "Limit must be a number between 1 and 1000"}}},
+		{"cursor=not-a-cursor", []fieldError{{"cursor", invalidFieldErrorCode, "Cursor is not valid"}}},
+		{"limit=-1&cursor=e30", []fieldError{
+			{"limit", invalidFieldErrorCode, "Limit must be a number between 1 and 1000"},
+			{"cursor", invalidFieldErrorCode, "Cursor is not valid"},
+		}},
+	}
+
+	for _, test := range tests {
+		writer := sendRequestForTesting(router, "GET", "/customers?"+test.query, nil)
+
+		assert.Equal(t, 400, writer.Code, test.query)
+
+		expected := newInvalidQueryProblem(test.expected)
+		expected.Instance = "/customers"
+
+		assert.Equal(t, getMockedProblemResponse(expected), getProblemFromResponse(t, writer), test.query)
+	}
+}
+
+func TestRepositoryListPages(t *testing.T) {
+	for name, repository := range getRepositoriesForTesting(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			// Added out of order, but listed by ID.
+			for _, id := range []string{"d", "b", "e", "a", "c"} {
+				mockedCustomer := getMockedCustomer()
+				mockedCustomer.ID = id
+
+				_, err := repository.Create(ctx, mockedCustomer)
+				assert.NoError(t, err)
+			}
+
+			page, err := repository.List(ctx, listQuery{Limit: 3})
+
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"a", "b", "c"}, getCustomerIds(page.Customers))
+			assert.Equal(t, 5, page.Total)
+			assert.NotEmpty(t, page.Next)
+
+			page, err = repository.List(ctx, listQuery{Limit: 3, Cursor: page.Next})
+
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"d", "e"}, getCustomerIds(page.Customers))
+			assert.Empty(t, page.Next)
+
+			page, err = repository.List(ctx, listQuery{Limit: 5})
+
+			assert.NoError(t, err)
+			assert.Len(t, page.Customers, 5)
+			assert.Empty(t, page.Next)
+
+			_, err = repository.List(ctx, listQuery{Limit: 3, Cursor: "not-a-cursor"})
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+		})
+	}
+}
+
+func getCustomerIds(customers []customer) []string {
+	ids := []string{}
+
+	for _, customer := range customers {
+		ids = append(ids, customer.ID)
+	}
+
+	return ids
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// Media types of the patches accepted by PATCH /customer/:id.
+const (
+	// mergePatchContentType is a JSON Merge Patch (RFC 7396): the fields
+	// to change, with null for the ones to remove.
+	mergePatchContentType = "application/merge-patch+json"
+	// jsonPatchContentType is a JSON Patch (RFC 6902): a list of
+	// operations to apply in order.
+	jsonPatchContentType = "application/json-patch+json"
+)
+
+// acceptedPatchContentTypes is sent in the Accept-Patch header (RFC 5789).
+var acceptedPatchContentTypes = mergePatchContentType + ", " + jsonPatchContentType
+
+// maxPatchAttempts is how many times a patch without If-Match is applied
+// when the customer changes between reading and writing it.
+const maxPatchAttempts = 3
+
+// applyCustomerPatch applies patch, whose media type is contentType, to
+// storedCustomer. The patched customer isn't validated.
+func applyCustomerPatch(storedCustomer customer, contentType string, patch []byte) (customer, *problem) {
+	document, err := json.Marshal(storedCustomer)
+
+	if err != nil {
+		return customer{}, newStatusProblem(http.StatusInternalServerError)
+	}
+
+	switch contentType {
+	case mergePatchContentType:
+		if !json.Valid(patch) {
+			return customer{}, newInvalidBodyProblem(fmt.Errorf("patch is not valid JSON"))
+		}
+
+		document, err = jsonpatch.MergePatch(document, patch)
+
+		if err != nil {
+			return customer{}, newInvalidBodyProblem(err)
+		}
+	case jsonPatchContentType:
+		operations, err := jsonpatch.DecodePatch(patch)
+
+		if err != nil {
+			return customer{}, newInvalidBodyProblem(err)
+		}
+
+		document, err = operations.Apply(document)
+
+		// The patch is well formed, but doesn't fit the customer (for
+		// example, a test operation failed).
+		if err != nil {
+			return customer{}, newProblem(http.StatusConflict, patchConflictProblemType,
+				"Patch cannot be applied to the customer", err.Error())
+		}
+	default:
+		unsupportedProblem := newStatusProblem(http.StatusUnsupportedMediaType)
+		unsupportedProblem.Detail = fmt.Sprintf("Content-Type must be %s or %s", mergePatchContentType, jsonPatchContentType)
+
+		return customer{}, unsupportedProblem
+	}
+
+	// Fields that customers don't have can't be added.
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.DisallowUnknownFields()
+
+	var patchedCustomer customer
+
+	if err := decoder.Decode(&patchedCustomer); err != nil {
+		return customer{}, newInvalidBodyProblem(err)
+	}
+
+	return patchedCustomer, nil
+}
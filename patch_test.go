@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func sendPatchForTesting(router *gin.Engine, path string, contentType string, patch string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest("PATCH", path, strings.NewReader(patch))
+	request.Header.Set("Content-Type", contentType)
+
+	writer := httptest.NewRecorder()
+	router.ServeHTTP(writer, request)
+
+	return writer
+}
+
+func setupPatchTest(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	writer := sendRequestForTesting(router, "POST", "/customer", getMockedCustomer())
+	assert.Equal(t, 201, writer.Code)
+
+	return router
+}
+
+func TestPatchCustomer(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+	}{
+		{"merge patch", mergePatchContentType, `{"email": "new.email@gmail.com"}`},
+		{"merge patch with charset", mergePatchContentType + "; charset=utf-8", `{"email": "new.email@gmail.com"}`},
+		{"JSON patch", jsonPatchContentType, `[{"op": "replace", "path": "/email", "value": "new.email@gmail.com"}]`},
+		{"JSON patch with test", jsonPatchContentType, `[
+			{"op": "test", "path": "/email", "value": "` + getMockedCustomer().Email + `"},
+			{"op": "replace", "path": "/email", "value": "new.email@gmail.com"}
+		]`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := setupPatchTest(t)
+			defer clearCustomers(nil)
+
+			writer := sendPatchForTesting(router, "/customer/1", test.contentType, test.patch)
+			assert.Equal(t, 200, writer.Code)
+
+			expected := getMockedCustomer()
+			expected.Email = "new.email@gmail.com"
+
+			var got customer
+			assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &got))
+			assert.Equal(t, expected, got)
+
+			stored, err := repository.Get(context.Background(), "1")
+			assert.NoError(t, err)
+			assert.Equal(t, withVersion(expected, 2), stored)
+		})
+	}
+}
+
+func TestPatchCustomerWithErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		patch       string
+		expected    *problem
+	}{
+		{"unknown customer", "/customer/2", mergePatchContentType, `{"name": "John"}`,
+			newProblem(404, customerNotFoundProblemType, "Customer not found", "")},
+		{"unsupported content type", "/customer/1", "application/json", `{"name": "John"}`,
+			newProblem(415, blankProblemType, "Unsupported Media Type",
+				"Content-Type must be application/merge-patch+json or application/json-patch+json")},
+		{"removed field", "/customer/1", mergePatchContentType, `{"name": null, "email": "not an email"}`,
+			newValidationProblem([]fieldError{
+				{"name", requiredFieldErrorCode, "Name cannot be null or empty"},
+				{"email", invalidFieldErrorCode, "Email is not valid"},
+			})},
+		{"changed ID", "/customer/1", jsonPatchContentType, `[{"op": "replace", "path": "/id", "value": "2"}]`,
+			newValidationProblem([]fieldError{{"id", notAllowedFieldErrorCode, "ID cannot be changed"}})},
+		{"removed ID", "/customer/1", mergePatchContentType, `{"id": null}`,
+			newValidationProblem([]fieldError{{"id", notAllowedFieldErrorCode, "ID cannot be changed"}})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := setupPatchTest(t)
+			defer clearCustomers(nil)
+
+			writer := sendPatchForTesting(router, test.path, test.contentType, test.patch)
+			assert.Equal(t, test.expected.Status, writer.Code)
+
+			test.expected.Instance = test.path
+
+			assert.Equal(t, getMockedProblemResponse(test.expected), getProblemFromResponse(t, writer))
+			assert.Equal(t, acceptedPatchContentTypes, writer.Header().Get("Accept-Patch"))
+
+			stored, err := repository.Get(context.Background(), "1")
+			assert.NoError(t, err)
+			assert.Equal(t, withVersion(getMockedCustomer(), 1), stored)
+		})
+	}
+}
+
+func TestPatchCustomerWithInvalidPatches(t *testing.T) {
+	tests := []struct {
+		name           string
+		contentType    string
+		patch          string
+		expectedStatus int
+		expectedType   string
+	}{
+		{"malformed merge patch", mergePatchContentType, `{"name": `, 400, invalidBodyProblemType},
+		{"merge patch that isn't an object", mergePatchContentType, `"John"`, 400, invalidBodyProblemType},
+		{"merge patch with unknown field", mergePatchContentType, `{"age": 30}`, 400, invalidBodyProblemType},
+		{"merge patch with wrong type", mergePatchContentType, `{"name": 30}`, 400, invalidBodyProblemType},
+		{"malformed JSON patch", jsonPatchContentType, `{"op": "replace"}`, 400, invalidBodyProblemType},
+		{"failed test", jsonPatchContentType, `[{"op": "test", "path": "/name", "value": "John"}]`, 409, patchConflictProblemType},
+		{"missing path", jsonPatchContentType, `[{"op": "remove", "path": "/nickname"}]`, 409, patchConflictProblemType},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := setupPatchTest(t)
+			defer clearCustomers(nil)
+
+			writer := sendPatchForTesting(router, "/customer/1", test.contentType, test.patch)
+			assert.Equal(t, test.expectedStatus, writer.Code)
+
+			got := getProblemFromResponse(t, writer)
+			assert.Equal(t, test.expectedType, got["type"])
+			assert.NotEmpty(t, got["detail"])
+		})
+	}
+}
+
+// racingCustomerRepository changes the surname of the customer right after
+// the next races reads, as another client would between the read and the
+// write of a PATCH.
+type racingCustomerRepository struct {
+	CustomerRepository
+	races int
+}
+
+func (repository *racingCustomerRepository) Get(ctx context.Context, id string) (customer, error) {
+	storedCustomer, err := repository.CustomerRepository.Get(ctx, id)
+
+	if err == nil && repository.races > 0 {
+		repository.races--
+
+		changedCustomer := storedCustomer
+		changedCustomer.Surname = "Constantine"
+		repository.CustomerRepository.Update(ctx, id, changedCustomer)
+	}
+
+	return storedCustomer, err
+}
+
+func TestPatchCustomerChangedConcurrently(t *testing.T) {
+	router := setupPatchTest(t)
+	defer clearCustomers(nil)
+
+	// The patch is applied again to the changed customer, keeping both
+	// changes.
+	repository = &racingCustomerRepository{CustomerRepository: repository, races: 1}
+
+	writer := sendPatchForTesting(router, "/customer/1", mergePatchContentType, `{"email": "new.email@gmail.com"}`)
+	assert.Equal(t, 200, writer.Code)
+
+	expected := getMockedCustomer()
+	expected.Surname = "Constantine"
+	expected.Email = "new.email@gmail.com"
+
+	stored, err := repository.Get(context.Background(), "1")
+	assert.NoError(t, err)
+	assert.Equal(t, withVersion(expected, 3), stored)
+
+	// It gives up if the customer keeps changing.
+	repository = &racingCustomerRepository{CustomerRepository: repository, races: maxPatchAttempts}
+
+	writer = sendPatchForTesting(router, "/customer/1", mergePatchContentType, `{"name": "Johnny"}`)
+	assert.Equal(t, 409, writer.Code)
+	assert.Equal(t, patchConflictProblemType, getProblemFromResponse(t, writer)["type"])
+
+	// With If-Match, the client wanted the version it sent.
+	stored, _ = repository.Get(context.Background(), "1")
+	repository = &racingCustomerRepository{CustomerRepository: repository, races: 1}
+
+	request := httptest.NewRequest("PATCH", "/customer/1", strings.NewReader(`{"name": "Johnny"}`))
+	request.Header.Set("Content-Type", mergePatchContentType)
+	request.Header.Set("If-Match", formatCustomerETag(stored.Version))
+	writer = httptest.NewRecorder()
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, 412, writer.Code)
+	assert.Equal(t, versionMismatchProblemType, getProblemFromResponse(t, writer)["type"])
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// problemContentType is the media type of the error responses, as defined
+// by RFC 7807.
+const problemContentType = "application/problem+json"
+
+// Types of the problems returned by the API. They identify the kind of
+// error, so clients can handle them without parsing the titles.
+const (
+	validationProblemType       = "/problems/validation-error"
+	invalidIdProblemType        = "/problems/invalid-id"
+	invalidBodyProblemType      = "/problems/invalid-body"
+	invalidQueryProblemType     = "/problems/invalid-query"
+	customerNotFoundProblemType = "/problems/customer-not-found"
+	customerConflictProblemType = "/problems/customer-conflict"
+	emailConflictProblemType    = "/problems/email-conflict"
+	patchConflictProblemType    = "/problems/patch-conflict"
+	versionMismatchProblemType  = "/problems/version-mismatch"
+	bulkRolledBackProblemType   = "/problems/bulk-rolled-back"
+	// blankProblemType is used when the HTTP status code is all there
+	// is to say about the error.
+	blankProblemType = "about:blank"
+)
+
+// problem is the body of every error response of the API, following
+// RFC 7807. Errors lists the invalid fields of a customer, if any, and
+// Results the outcome of each item of a bulk request that was rolled back.
+type problem struct {
+	// XMLName is the root element of the XML format of RFC 7807.
+	XMLName  xml.Name         `json:"-" xml:"urn:ietf:rfc:7807 problem" yaml:"-"`
+	Type     string           `json:"type" xml:"type" yaml:"type"`
+	Title    string           `json:"title" xml:"title" yaml:"title"`
+	Status   int              `json:"status" xml:"status" yaml:"status"`
+	Detail   string           `json:"detail,omitempty" xml:"detail,omitempty" yaml:"detail,omitempty"`
+	Instance string           `json:"instance,omitempty" xml:"instance,omitempty" yaml:"instance,omitempty"`
+	Errors   []fieldError     `json:"errors,omitempty" xml:"error,omitempty" yaml:"errors,omitempty"`
+	Results  []bulkItemResult `json:"results,omitempty" xml:"result,omitempty" yaml:"results,omitempty"`
+}
+
+func (p *problem) Error() string {
+	if p.Detail != "" {
+		return p.Title + ": " + p.Detail
+	}
+
+	return p.Title
+}
+
+func newProblem(status int, problemType string, title string, detail string) *problem {
+	return &problem{Type: problemType, Title: title, Status: status, Detail: detail}
+}
+
+// newStatusProblem returns a problem described only by its status code.
+func newStatusProblem(status int) *problem {
+	return newProblem(status, blankProblemType, http.StatusText(status), "")
+}
+
+func newValidationProblem(fieldErrors []fieldError) *problem {
+	validationProblem := newProblem(http.StatusBadRequest, validationProblemType, "Customer information is not valid", "")
+	validationProblem.Errors = fieldErrors
+
+	return validationProblem
+}
+
+func newInvalidBodyProblem(err error) *problem {
+	return newProblem(http.StatusBadRequest, invalidBodyProblemType, "Request body is not valid", err.Error())
+}
+
+// newRepositoryProblem translates an error returned by the repository into
+// the matching problem. If err is already a problem, it's returned as is.
+func newRepositoryProblem(err error) *problem {
+	var p *problem
+
+	switch {
+	case errors.As(err, &p):
+		return p
+	case errors.Is(err, ErrNotFound):
+		return newProblem(http.StatusNotFound, customerNotFoundProblemType, "Customer not found", "")
+	case errors.Is(err, ErrConflict):
+		return newProblem(http.StatusConflict, customerConflictProblemType, "Customer already exists", "")
+	case errors.Is(err, ErrEmailConflict):
+		return newProblem(http.StatusConflict, emailConflictProblemType, "Email is already in use", "")
+	case errors.Is(err, ErrVersionMismatch):
+		return newProblem(http.StatusPreconditionFailed, versionMismatchProblemType, "Customer was modified",
+			"The customer doesn't have the ETag sent in If-Match")
+	case errors.Is(err, ErrInvalidCursor):
+		return newInvalidQueryProblem([]fieldError{newInvalidCursorFieldError()})
+	default:
+		return newStatusProblem(http.StatusInternalServerError)
+	}
+}
+
+// abortWithProblem responds with p in the negotiated format, filling its
+// instance with the path of the request, and stops the remaining handlers.
+func abortWithProblem(p *problem, context *gin.Context) {
+	if p.Instance == "" && context.Request != nil && context.Request.URL != nil {
+		p.Instance = context.Request.URL.Path
+	}
+
+	format, isPretty := getResponseFormat(context)
+
+	context.Header("Content-Type", format.ProblemMediaType)
+	context.Abort()
+	context.Render(p.Status, format.Render(p, isPretty))
+}
+
+// problemMiddleware makes sure no error leaves the API without a problem
+// as its body: errors added with context.Error by handlers that didn't
+// respond are rendered once the handlers are done. Handlers must bind with
+// ShouldBind, since Bind sends the status code before this can respond.
+func problemMiddleware() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		context.Next()
+
+		lastError := context.Errors.Last()
+
+		if lastError == nil || context.Writer.Written() {
+			return
+		}
+
+		var p *problem
+
+		switch {
+		case errors.As(lastError.Err, &p):
+		case lastError.IsType(gin.ErrorTypeBind):
+			p = newInvalidBodyProblem(lastError.Err)
+		default:
+			p = newStatusProblem(http.StatusInternalServerError)
+		}
+
+		abortWithProblem(p, context)
+	}
+}
+
+// recoverWithProblem responds with a problem when a handler panics.
+func recoverWithProblem(context *gin.Context, recovered interface{}) {
+	abortWithProblem(newStatusProblem(http.StatusInternalServerError), context)
+}
+
+// noRouteProblem and noMethodProblem respond to requests that don't match
+// any endpoint.
+func noRouteProblem(context *gin.Context) {
+	abortWithProblem(newStatusProblem(http.StatusNotFound), context)
+}
+
+func noMethodProblem(context *gin.Context) {
+	abortWithProblem(newStatusProblem(http.StatusMethodNotAllowed), context)
+}
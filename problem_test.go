@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func getProblemFromResponse(t *testing.T, writer *httptest.ResponseRecorder) gin.H {
+	assert.Equal(t, problemContentType, writer.Header().Get("Content-Type"))
+
+	var got gin.H
+
+	if err := json.Unmarshal(writer.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	return got
+}
+
+func TestProblemWithMalformedBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	for _, method := range []string{"POST", "PUT"} {
+		path := "/customer"
+
+		if method == "PUT" {
+			writer := sendRequestForTesting(router, "POST", "/customer", getMockedCustomer())
+			assert.Equal(t, 201, writer.Code)
+
+			path = "/customer/1"
+		}
+
+		request := httptest.NewRequest(method, path, strings.NewReader(`{"name": `))
+		request.Header.Set("Content-Type", "application/json")
+		writer := httptest.NewRecorder()
+		router.ServeHTTP(writer, request)
+
+		assert.Equal(t, 400, writer.Code)
+
+		got := getProblemFromResponse(t, writer)
+
+		assert.Equal(t, invalidBodyProblemType, got["type"])
+		assert.Equal(t, "Request body is not valid", got["title"])
+		assert.Equal(t, float64(400), got["status"])
+		assert.Equal(t, path, got["instance"])
+		assert.NotEmpty(t, got["detail"])
+	}
+}
+
+func TestProblemWithUnknownRouteAndMethod(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := setupRouter()
+
+	writer := sendRequestForTesting(router, "GET", "/unknown", nil)
+
+	assert.Equal(t, 404, writer.Code)
+	assert.Equal(t, gin.H{
+		"type":     "about:blank",
+		"title":    "Not Found",
+		"status":   float64(404),
+		"instance": "/unknown",
+	}, getProblemFromResponse(t, writer))
+
+	writer = sendRequestForTesting(router, "PATCH", "/customers", nil)
+
+	assert.Equal(t, 405, writer.Code)
+	assert.Equal(t, gin.H{
+		"type":     "about:blank",
+		"title":    "Method Not Allowed",
+		"status":   float64(405),
+		"instance": "/customers",
+	}, getProblemFromResponse(t, writer))
+}
+
+func TestProblemMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(gin.CustomRecovery(recoverWithProblem), problemMiddleware())
+
+	router.GET("/problem", func(context *gin.Context) {
+		context.Error(newProblem(http.StatusConflict, customerConflictProblemType, "Customer already exists", "Try another ID"))
+	})
+	router.GET("/bind", func(context *gin.Context) {
+		context.Error(errors.New("unexpected end of JSON input")).SetType(gin.ErrorTypeBind)
+	})
+	router.GET("/error", func(context *gin.Context) {
+		context.Error(errors.New("disk is full"))
+	})
+	router.GET("/panic", func(context *gin.Context) {
+		panic("something went wrong")
+	})
+	router.GET("/responded", func(context *gin.Context) {
+		context.Error(errors.New("already handled"))
+		context.IndentedJSON(http.StatusOK, gin.H{"message": "ok"})
+	})
+
+	tests := []struct {
+		path     string
+		expected gin.H
+	}{
+		{"/problem", gin.H{"type": customerConflictProblemType, "title": "Customer already exists", "status": float64(409), "detail": "Try another ID", "instance": "/problem"}},
+		{"/bind", gin.H{"type": invalidBodyProblemType, "title": "Request body is not valid", "status": float64(400), "detail": "unexpected end of JSON input", "instance": "/bind"}},
+		{"/error", gin.H{"type": "about:blank", "title": "Internal Server Error", "status": float64(500), "instance": "/error"}},
+		{"/panic", gin.H{"type": "about:blank", "title": "Internal Server Error", "status": float64(500), "instance": "/panic"}},
+	}
+
+	for _, test := range tests {
+		writer := sendRequestForTesting(router, "GET", test.path, nil)
+
+		assert.Equal(t, test.expected, getProblemFromResponse(t, writer), test.path)
+	}
+
+	writer := sendRequestForTesting(router, "GET", "/responded", nil)
+
+	assert.Equal(t, 200, writer.Code)
+	assert.Equal(t, "application/json; charset=utf-8", writer.Header().Get("Content-Type"))
+}
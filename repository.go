@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned by a CustomerRepository when no customer
+	// matches the requested ID.
+	ErrNotFound = errors.New("customer not found")
+
+	// ErrConflict is returned by a CustomerRepository when storing a
+	// customer would collide with the ID of one that already exists.
+	ErrConflict = errors.New("customer already exists")
+
+	// ErrInvalidCursor is returned by CustomerRepository.List when the
+	// cursor wasn't returned by a previous call.
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrEmailConflict is returned by a CustomerRepository that enforces
+	// unique emails when another customer already uses the same email,
+	// ignoring case and surrounding spaces.
+	ErrEmailConflict = errors.New("email already in use")
+
+	// ErrVersionMismatch is returned by CustomerRepository.Update and
+	// Delete when the customer isn't at the version required by
+	// ifVersion.
+	ErrVersionMismatch = errors.New("customer version mismatch")
+)
+
+// listQuery selects a page of customers. Only the customers that pass
+// every filter are listed, ordered by Sort and then by ID. Limit is the
+// maximum number of customers of the page, and Cursor comes from the
+// previous page (or is empty to get the first one).
+type listQuery struct {
+	Limit   int
+	Cursor  string
+	Filters []customerFilter
+	Sort    []sortKey
+}
+
+// normalizedLimit returns the limit of the query, replacing values out of
+// range with the default one.
+func (query listQuery) normalizedLimit() int {
+	if query.Limit <= 0 || query.Limit > maxPageLimit {
+		return defaultPageLimit
+	}
+
+	return query.Limit
+}
+
+// decodeCursor returns the values of the sort keys (normalized) of the last
+// customer of the previous page, or nil for the first page. It returns
+// ErrInvalidCursor if the cursor comes from a list sorted differently.
+func (query listQuery) decodeCursor() ([]string, error) {
+	if query.Cursor == "" {
+		return nil, nil
+	}
+
+	position, err := decodeCursor(query.Cursor)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sortKeys := normalizeSort(query.Sort)
+
+	if position.Sort != formatSort(sortKeys) || len(position.Values) != len(sortKeys) {
+		return nil, ErrInvalidCursor
+	}
+
+	return position.Values, nil
+}
+
+// encodeCursor returns the cursor of the page following the one that ends
+// with lastCustomer.
+func (query listQuery) encodeCursor(lastCustomer customer) string {
+	sortKeys := normalizeSort(query.Sort)
+
+	return encodeCursor(cursor{Sort: formatSort(sortKeys), Values: getSortValues(lastCustomer, sortKeys)})
+}
+
+// customerPage is a page of customers. Next is the cursor of the following
+// page, or empty if this is the last one. Total counts the customers of
+// every page.
+type customerPage struct {
+	Customers []customer
+	Next      string
+	Total     int
+}
+
+// forEachCustomer calls fn with every customer in the repository, a page
+// at a time, so they don't have to be loaded at once.
+func forEachCustomer(ctx context.Context, repository CustomerRepository, fn func(customer) error) error {
+	return forEachMatchingCustomer(ctx, repository, listQuery{}, fn)
+}
+
+// forEachMatchingCustomer calls fn with every customer selected by the
+// filters and sort of query, a page at a time. The limit and cursor of
+// query are ignored.
+func forEachMatchingCustomer(ctx context.Context, repository CustomerRepository, query listQuery, fn func(customer) error) error {
+	query.Limit = maxPageLimit
+	query.Cursor = ""
+
+	for {
+		page, err := repository.List(ctx, query)
+
+		if err != nil {
+			return err
+		}
+
+		for _, customer := range page.Customers {
+			if err := fn(customer); err != nil {
+				return err
+			}
+		}
+
+		if page.Next == "" {
+			return nil
+		}
+
+		query.Cursor = page.Next
+	}
+}
+
+// repositoryOptions holds the behaviour shared by every
+// CustomerRepository implementation.
+type repositoryOptions struct {
+	uniqueEmails bool
+}
+
+// repositoryOption customizes a CustomerRepository when it's created.
+type repositoryOption func(*repositoryOptions)
+
+// withUniqueEmails makes the repository reject customers whose email is
+// already used by another customer with ErrEmailConflict.
+func withUniqueEmails() repositoryOption {
+	return func(options *repositoryOptions) {
+		options.uniqueEmails = true
+	}
+}
+
+func newRepositoryOptions(options []repositoryOption) repositoryOptions {
+	var result repositoryOptions
+
+	for _, option := range options {
+		option(&result)
+	}
+
+	return result
+}
+
+// writeConditions holds the requirements a customer must meet to be
+// updated or deleted.
+type writeConditions struct {
+	// version is the only version the customer can be at, or 0 if any
+	// version is fine.
+	version uint64
+}
+
+// writeCondition restricts a CustomerRepository.Update or Delete.
+type writeCondition func(*writeConditions)
+
+// ifVersion makes the write fail with ErrVersionMismatch unless the
+// customer is still at the given version.
+func ifVersion(version uint64) writeCondition {
+	return func(conditions *writeConditions) {
+		conditions.version = version
+	}
+}
+
+// expectVersion returns the conditions of a write that expects the
+// customer to be at expectedVersion, where 0 means any version.
+func expectVersion(expectedVersion uint64) []writeCondition {
+	if expectedVersion == 0 {
+		return nil
+	}
+
+	return []writeCondition{ifVersion(expectedVersion)}
+}
+
+func newWriteConditions(conditions []writeCondition) writeConditions {
+	var result writeConditions
+
+	for _, condition := range conditions {
+		condition(&result)
+	}
+
+	return result
+}
+
+// allowsVersion reports whether a customer at version meets the
+// conditions.
+func (conditions writeConditions) allowsVersion(version uint64) bool {
+	return conditions.version == 0 || conditions.version == version
+}
+
+// normalizeEmail returns the form of an email used to compare it with
+// others when emails must be unique.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// CustomerRepository abstracts the storage of customers so the HTTP
+// handlers don't depend on where the information is kept.
+type CustomerRepository interface {
+	// Create stores a new customer at version 1, or right after the
+	// highest version of the deleted customers, so a customer created
+	// again with the ID of a deleted one never has one of its versions
+	// and ETags. It returns ErrConflict if a
+	// customer with the same ID already exists, or ErrEmailConflict.
+	Create(ctx context.Context, newCustomer customer) (customer, error)
+
+	// Get returns the customer whose ID matches the given one, or
+	// ErrNotFound.
+	Get(ctx context.Context, id string) (customer, error)
+
+	// List returns a page of the stored customers selected by the query,
+	// or ErrInvalidCursor.
+	List(ctx context.Context, query listQuery) (customerPage, error)
+
+	// Update replaces the information of the customer with the given ID,
+	// increasing its version, and returns the stored result, or
+	// ErrNotFound, ErrVersionMismatch or ErrEmailConflict.
+	Update(ctx context.Context, id string, newCustomerInformation customer, conditions ...writeCondition) (customer, error)
+
+	// Delete removes the customer with the given ID, or returns
+	// ErrNotFound or ErrVersionMismatch.
+	Delete(ctx context.Context, id string, conditions ...writeCondition) error
+}
+
+// customerTransactor is implemented by the repositories that can apply
+// several changes at once.
+type customerTransactor interface {
+	// WithinTransaction calls fn with a repository whose changes are all
+	// kept if fn returns nil, or all thrown away otherwise.
+	WithinTransaction(ctx context.Context, fn func(transaction CustomerRepository) error) error
+}
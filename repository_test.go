@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// getRepositoriesForTesting returns a fresh instance of every
+// CustomerRepository implementation, keyed by a descriptive name.
+func getRepositoriesForTesting(t *testing.T, options ...repositoryOption) map[string]CustomerRepository {
+	sqliteRepository, err := newSQLiteCustomerRepository(filepath.Join(t.TempDir(), "customers.db"), options...)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { sqliteRepository.Close() })
+
+	return map[string]CustomerRepository{
+		"in-memory": newInMemoryCustomerRepository(options...),
+		"sqlite":    sqliteRepository,
+	}
+}
+
+// listAllCustomers returns every customer in the repository.
+func listAllCustomers(ctx context.Context, repository CustomerRepository) ([]customer, error) {
+	customers := []customer{}
+
+	err := forEachCustomer(ctx, repository, func(customer customer) error {
+		customers = append(customers, customer)
+		return nil
+	})
+
+	return customers, err
+}
+
+// withVersion returns storedCustomer at the given version, as it's
+// returned by the repositories.
+func withVersion(storedCustomer customer, version uint64) customer {
+	storedCustomer.Version = version
+
+	return storedCustomer
+}
+
+func TestRepositoryCreateAndGet(t *testing.T) {
+	for name, repository := range getRepositoriesForTesting(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			created, err := repository.Create(ctx, getMockedCustomer())
+
+			assert.NoError(t, err)
+			assert.Equal(t, withVersion(getMockedCustomer(), 1), created)
+
+			got, err := repository.Get(ctx, "1")
+
+			assert.NoError(t, err)
+			assert.Equal(t, withVersion(getMockedCustomer(), 1), got)
+		})
+	}
+}
+
+func TestRepositoryCreateWithDuplicatedId(t *testing.T) {
+	for name, repository := range getRepositoriesForTesting(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			_, err := repository.Create(ctx, getMockedCustomer())
+			assert.NoError(t, err)
+
+			_, err = repository.Create(ctx, getMockedCustomer())
+			assert.ErrorIs(t, err, ErrConflict)
+		})
+	}
+}
+
+func TestRepositoryGetWithNonExistentId(t *testing.T) {
+	for name, repository := range getRepositoriesForTesting(t) {
+		t.Run(name, func(t *testing.T) {
+			_, err := repository.Get(context.Background(), "1")
+
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestRepositoryList(t *testing.T) {
+	for name, repository := range getRepositoriesForTesting(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			customers, err := listAllCustomers(ctx, repository)
+			assert.NoError(t, err)
+			assert.Empty(t, customers)
+
+			for _, mockedCustomer := range getMockedCustomers() {
+				_, err := repository.Create(ctx, mockedCustomer)
+				assert.NoError(t, err)
+			}
+
+			customers, err = listAllCustomers(ctx, repository)
+
+			expected := []customer{}
+
+			for _, mockedCustomer := range getMockedCustomers() {
+				expected = append(expected, withVersion(mockedCustomer, 1))
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, expected, customers)
+		})
+	}
+}
+
+func TestRepositoryUpdate(t *testing.T) {
+	for name, repository := range getRepositoriesForTesting(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			_, err := repository.Create(ctx, getMockedCustomer())
+			assert.NoError(t, err)
+
+			updated, err := repository.Update(ctx, "1", getMockedUpdatedCustomerInformation())
+
+			assert.NoError(t, err)
+			assert.Equal(t, withVersion(getMockedUpdatedCustomerInformation(), 2), updated)
+
+			got, err := repository.Get(ctx, "1")
+
+			assert.NoError(t, err)
+			assert.Equal(t, withVersion(getMockedUpdatedCustomerInformation(), 2), got)
+
+			_, err = repository.Update(ctx, "2", getMockedUpdatedCustomerInformation())
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestRepositoryDelete(t *testing.T) {
+	for name, repository := range getRepositoriesForTesting(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			for _, mockedCustomer := range getMockedCustomers() {
+				_, err := repository.Create(ctx, mockedCustomer)
+				assert.NoError(t, err)
+			}
+
+			assert.NoError(t, repository.Delete(ctx, "1"))
+
+			_, err := repository.Get(ctx, "1")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			_, err = repository.Get(ctx, "2")
+			assert.NoError(t, err)
+
+			assert.ErrorIs(t, repository.Delete(ctx, "1"), ErrNotFound)
+		})
+	}
+}
+
+func TestSQLiteRepositoryKeepsCustomersAfterReopening(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "customers.db")
+
+	repository, err := newSQLiteCustomerRepository(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repository.Create(context.Background(), getMockedCustomer())
+	assert.NoError(t, err)
+	assert.NoError(t, repository.Close())
+
+	repository, err = newSQLiteCustomerRepository(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer repository.Close()
+
+	got, err := repository.Get(context.Background(), "1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, withVersion(getMockedCustomer(), 1), got)
+}
+
+func TestRepositoryDeleteWithNonSequentialIds(t *testing.T) {
+	ids := []string{"b7f2", "customer-42", "9", "1", "a"}
+
+	for name, repository := range getRepositoriesForTesting(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			for _, id := range ids {
+				mockedCustomer := getMockedCustomer()
+				mockedCustomer.ID = id
+
+				_, err := repository.Create(ctx, mockedCustomer)
+				assert.NoError(t, err)
+			}
+
+			assert.NoError(t, repository.Delete(ctx, "9"))
+			assert.NoError(t, repository.Delete(ctx, "customer-42"))
+			assert.ErrorIs(t, repository.Delete(ctx, "2"), ErrNotFound)
+
+			customers, err := listAllCustomers(ctx, repository)
+			assert.NoError(t, err)
+
+			remainingIds := []string{}
+
+			for _, remainingCustomer := range customers {
+				remainingIds = append(remainingIds, remainingCustomer.ID)
+			}
+
+			assert.Equal(t, []string{"1", "a", "b7f2"}, remainingIds)
+		})
+	}
+}
+
+func TestRepositoryWithUniqueEmails(t *testing.T) {
+	for name, repository := range getRepositoriesForTesting(t, withUniqueEmails()) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			_, err := repository.Create(ctx, getMockedCustomer())
+			assert.NoError(t, err)
+
+			sameEmailCustomer := getMockedCustomers()[1]
+			sameEmailCustomer.Email = " Augusto.Giavedoni@GMAIL.com"
+
+			_, err = repository.Create(ctx, sameEmailCustomer)
+			assert.ErrorIs(t, err, ErrEmailConflict)
+
+			_, err = repository.Create(ctx, getMockedCustomers()[1])
+			assert.NoError(t, err)
+
+			// A customer can keep its own email, but can't take another's.
+			_, err = repository.Update(ctx, "1", getMockedCustomer())
+			assert.NoError(t, err)
+
+			_, err = repository.Update(ctx, "2", sameEmailCustomer)
+			assert.ErrorIs(t, err, ErrEmailConflict)
+
+			// The email is free again once its customer is deleted.
+			assert.NoError(t, repository.Delete(ctx, "1"))
+
+			_, err = repository.Update(ctx, "2", sameEmailCustomer)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestRepositoryWithoutUniqueEmails(t *testing.T) {
+	for name, repository := range getRepositoriesForTesting(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			_, err := repository.Create(ctx, getMockedCustomer())
+			assert.NoError(t, err)
+
+			sameEmailCustomer := getMockedCustomers()[1]
+			sameEmailCustomer.Email = getMockedCustomer().Email
+
+			_, err = repository.Create(ctx, sameEmailCustomer)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestRepositoryConcurrentCreateKeepsIdsAndEmailsUnique(t *testing.T) {
+	for name, repository := range getRepositoriesForTesting(t, withUniqueEmails()) {
+		t.Run(name, func(t *testing.T) {
+			var wait sync.WaitGroup
+			var mutex sync.Mutex
+			results := map[error]int{}
+
+			for worker := 0; worker < concurrentWorkers; worker++ {
+				wait.Add(1)
+
+				go func(worker int) {
+					defer wait.Done()
+
+					// Half of the workers share the same ID, the other
+					// half the same email.
+					mockedCustomer := getMockedCustomer()
+
+					if worker%2 == 0 {
+						mockedCustomer.ID = "same"
+						mockedCustomer.Email = strconv.Itoa(worker) + "@example.com"
+					} else {
+						mockedCustomer.ID = strconv.Itoa(worker)
+						mockedCustomer.Email = "same@example.com"
+					}
+
+					_, err := repository.Create(context.Background(), mockedCustomer)
+
+					mutex.Lock()
+					results[err]++
+					mutex.Unlock()
+				}(worker)
+			}
+
+			wait.Wait()
+
+			assert.Equal(t, map[error]int{
+				nil:              2,
+				ErrConflict:      concurrentWorkers/2 - 1,
+				ErrEmailConflict: concurrentWorkers/2 - 1,
+			}, results)
+		})
+	}
+}
+
+func TestSQLiteRepositoryCannotEnforceUniqueEmailsWithDuplicates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "customers.db")
+
+	repository, err := newSQLiteCustomerRepository(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sameEmailCustomer := getMockedCustomers()[1]
+	sameEmailCustomer.Email = getMockedCustomer().Email
+
+	_, err = repository.Create(context.Background(), getMockedCustomer())
+	assert.NoError(t, err)
+	_, err = repository.Create(context.Background(), sameEmailCustomer)
+	assert.NoError(t, err)
+	assert.NoError(t, repository.Close())
+
+	_, err = newSQLiteCustomerRepository(path, withUniqueEmails())
+
+	assert.Error(t, err)
+}
+
+func TestSQLiteRepositoryMigratesExistingDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "customers.db")
+
+	// Create the database as it was before emails were normalized.
+	db, err := sql.Open("sqlite", path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE customers (
+		id        TEXT NOT NULL PRIMARY KEY,
+		name      TEXT NOT NULL,
+		surname   TEXT NOT NULL,
+		email     TEXT NOT NULL,
+		birthdate TEXT NOT NULL
+	)`)
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO customers VALUES ('1', 'Augusto', 'Giavedoni', 'AUGUSTO.GIAVEDONI@GMAIL.COM', '2000-02-20')")
+	assert.NoError(t, err)
+	_, err = db.Exec("PRAGMA user_version = 1")
+	assert.NoError(t, err)
+	assert.NoError(t, db.Close())
+
+	repository, err := newSQLiteCustomerRepository(path, withUniqueEmails())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer repository.Close()
+
+	_, err = repository.Create(context.Background(), getMockedCustomers()[1])
+	assert.NoError(t, err)
+
+	sameEmailCustomer := getMockedCustomers()[1]
+	sameEmailCustomer.ID = "3"
+	sameEmailCustomer.Email = getMockedCustomer().Email
+
+	_, err = repository.Create(context.Background(), sameEmailCustomer)
+	assert.ErrorIs(t, err, ErrEmailConflict)
+}
+
+func TestSQLiteRepositoryMigratesDeletedVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "customers.db")
+
+	// Create the database as it was when the last version of each deleted
+	// customer was kept.
+	db, err := sql.Open("sqlite", path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, statement := range []string{
+		`CREATE TABLE customers (
+			id        TEXT NOT NULL PRIMARY KEY,
+			name      TEXT NOT NULL,
+			surname   TEXT NOT NULL,
+			email     TEXT NOT NULL,
+			birthdate TEXT NOT NULL,
+			email_key TEXT NOT NULL DEFAULT '',
+			version   INTEGER NOT NULL DEFAULT 1
+		)`,
+		"CREATE TABLE deleted_customers (id TEXT NOT NULL PRIMARY KEY, version INTEGER NOT NULL)",
+		"INSERT INTO deleted_customers VALUES ('1', 7), ('2', 3)",
+		"PRAGMA user_version = 4",
+	} {
+		_, err = db.Exec(statement)
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, db.Close())
+
+	repository, err := newSQLiteCustomerRepository(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer repository.Close()
+
+	created, err := repository.Create(context.Background(), getMockedCustomers()[1])
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(8), created.Version)
+
+	var tables int
+	assert.NoError(t, repository.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'deleted_customers'").Scan(&tables))
+	assert.Zero(t, tables)
+}
@@ -0,0 +1,552 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// defaultSearchLimit is the number of results returned when the client
+	// doesn't ask for a different one.
+	defaultSearchLimit = 20
+	// maxSearchLimit is the highest number of results of a search.
+	maxSearchLimit = 100
+	// minSearchScore is the lowest score a customer needs to be a result,
+	// and a word to be highlighted.
+	minSearchScore = 0.3
+
+	searchQueryParameter = "q"
+)
+
+// searchableFields are the fields of a customer that are searched.
+var searchableFields = []string{"name", "surname", "email"}
+
+// searchQuery is a search of customers. Text is what the client is looking
+// for and Limit the maximum number of results.
+type searchQuery struct {
+	Text  string
+	Limit int
+}
+
+// searchResult is a customer found by a search. Score goes from 0 to 1,
+// where 1 means every word searched was found as is. Highlights has the
+// matched fields, HTML escaped, with the matched words between <em> tags.
+type searchResult struct {
+	Customer   customer         `json:"customer" xml:"customer" yaml:"customer"`
+	Score      float64          `json:"score" xml:"score" yaml:"score"`
+	Highlights searchHighlights `json:"highlights" xml:"highlights" yaml:"highlights"`
+}
+
+// searchHighlights has the highlighted value of each matched field.
+type searchHighlights map[string]string
+
+// MarshalXML writes each highlight as an element named after its field,
+// since XML has no maps.
+func (highlights searchHighlights) MarshalXML(encoder *xml.Encoder, start xml.StartElement) error {
+	fields := []string{}
+
+	for field := range highlights {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		if err := encoder.EncodeElement(highlights[field], xml.StartElement{Name: xml.Name{Local: field}}); err != nil {
+			return err
+		}
+	}
+
+	return encoder.EncodeToken(start.End())
+}
+
+// searchResponse is the body of GET /customers/search. Total is the number
+// of customers found, even if only Limit of them are returned.
+type searchResponse struct {
+	XMLName xml.Name       `json:"-" xml:"search" yaml:"-"`
+	Data    []searchResult `json:"data" xml:"data>result" yaml:"data"`
+	Total   int            `json:"total" xml:"total" yaml:"total"`
+}
+
+// customerSearcher is implemented by the repositories that can search
+// customers.
+type customerSearcher interface {
+	Search(ctx context.Context, query searchQuery) ([]searchResult, int, error)
+}
+
+// parseSearchQuery reads the q and limit query parameters, returning every
+// problem found with them.
+func parseSearchQuery(parameters url.Values) (searchQuery, []fieldError) {
+	query := searchQuery{Text: strings.TrimSpace(parameters.Get(searchQueryParameter)), Limit: defaultSearchLimit}
+	fieldErrors := []fieldError{}
+
+	if len(tokenizeSearchText(query.Text)) == 0 {
+		fieldErrors = append(fieldErrors, fieldError{searchQueryParameter, requiredFieldErrorCode,
+			"Search must have at least one letter or digit"})
+	}
+
+	if limitParameter := parameters.Get(listLimitParameter); limitParameter != "" {
+		limit, err := strconv.Atoi(limitParameter)
+
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			fieldErrors = append(fieldErrors, fieldError{listLimitParameter, invalidFieldErrorCode,
+				fmt.Sprintf("Limit must be a number between 1 and %d", maxSearchLimit)})
+		} else {
+			query.Limit = limit
+		}
+	}
+
+	return query, fieldErrors
+}
+
+// searchToken is a word of a searched field or of a search. Text is the
+// word folded by foldSearchText, and Start and End are the byte offsets of
+// the word on the original value.
+type searchToken struct {
+	Text     string
+	Start    int
+	End      int
+	Trigrams map[string]struct{}
+}
+
+// searchDocument is what the index knows about a customer.
+type searchDocument struct {
+	Customer customer
+	Tokens   map[string][]searchToken
+}
+
+// searchIndex is an inverted index of the trigrams of the words of the
+// searchable fields of the customers. It finds customers whose words are
+// equal to, start with or are similar to the searched ones, so misspelled
+// and partial words are found too.
+type searchIndex struct {
+	mutex     sync.RWMutex
+	documents map[string]searchDocument
+	// postings has the IDs of the customers with words containing each
+	// trigram.
+	postings map[string]map[string]struct{}
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{documents: map[string]searchDocument{}, postings: map[string]map[string]struct{}{}}
+}
+
+// Add indexes indexedCustomer, replacing the customer with the same ID.
+func (index *searchIndex) Add(indexedCustomer customer) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	index.remove(indexedCustomer.ID)
+
+	document := searchDocument{Customer: indexedCustomer, Tokens: map[string][]searchToken{}}
+
+	for _, field := range searchableFields {
+		tokens := tokenizeSearchText(getCustomerField(indexedCustomer, field))
+		document.Tokens[field] = tokens
+
+		for _, token := range tokens {
+			for trigram := range token.Trigrams {
+				if index.postings[trigram] == nil {
+					index.postings[trigram] = map[string]struct{}{}
+				}
+
+				index.postings[trigram][indexedCustomer.ID] = struct{}{}
+			}
+		}
+	}
+
+	index.documents[indexedCustomer.ID] = document
+}
+
+// Remove stops finding the customer with the given ID.
+func (index *searchIndex) Remove(id string) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	index.remove(id)
+}
+
+func (index *searchIndex) remove(id string) {
+	document, isIndexed := index.documents[id]
+
+	if !isIndexed {
+		return
+	}
+
+	for _, tokens := range document.Tokens {
+		for _, token := range tokens {
+			for trigram := range token.Trigrams {
+				delete(index.postings[trigram], id)
+
+				if len(index.postings[trigram]) == 0 {
+					delete(index.postings, trigram)
+				}
+			}
+		}
+	}
+
+	delete(index.documents, id)
+}
+
+// Search returns the best results for query, from the highest score to the
+// lowest one (and then by ID), along with how many customers were found.
+func (index *searchIndex) Search(query searchQuery) ([]searchResult, int) {
+	queryTokens := tokenizeSearchText(query.Text)
+
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	candidates := map[string]struct{}{}
+
+	for _, queryToken := range queryTokens {
+		for trigram := range queryToken.Trigrams {
+			for id := range index.postings[trigram] {
+				candidates[id] = struct{}{}
+			}
+		}
+	}
+
+	results := []searchResult{}
+
+	for id := range candidates {
+		if result, isFound := scoreSearchDocument(index.documents[id], queryTokens); isFound {
+			results = append(results, result)
+		}
+	}
+
+	sort.Slice(results, func(i int, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+
+		return results[i].Customer.ID < results[j].Customer.ID
+	})
+
+	total := len(results)
+
+	if query.Limit > 0 && len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+
+	return results, total
+}
+
+// scoreSearchDocument scores document with the average of the best score of
+// each of queryTokens among the words of the document.
+func scoreSearchDocument(document searchDocument, queryTokens []searchToken) (searchResult, bool) {
+	totalScore := 0.0
+	// matchedTokens has the indexes of the highlighted words of each field.
+	matchedTokens := map[string]map[int]bool{}
+
+	for _, queryToken := range queryTokens {
+		bestScore := 0.0
+
+		for _, field := range searchableFields {
+			for i, token := range document.Tokens[field] {
+				score := scoreSearchToken(queryToken, token)
+
+				if score >= minSearchScore {
+					if matchedTokens[field] == nil {
+						matchedTokens[field] = map[int]bool{}
+					}
+
+					matchedTokens[field][i] = true
+				}
+
+				bestScore = math.Max(bestScore, score)
+			}
+		}
+
+		totalScore += bestScore
+	}
+
+	score := totalScore / float64(len(queryTokens))
+
+	if score < minSearchScore {
+		return searchResult{}, false
+	}
+
+	highlights := map[string]string{}
+
+	for field, tokenIndexes := range matchedTokens {
+		highlights[field] = highlightSearchTokens(getCustomerField(document.Customer, field), document.Tokens[field], tokenIndexes)
+	}
+
+	return searchResult{Customer: document.Customer, Score: math.Round(score*1000) / 1000, Highlights: highlights}, true
+}
+
+// scoreSearchToken returns 1 if token is the searched word, more than 0.5
+// if it starts with it, and otherwise how similar their trigrams are.
+func scoreSearchToken(queryToken searchToken, token searchToken) float64 {
+	if token.Text == queryToken.Text {
+		return 1
+	}
+
+	if strings.HasPrefix(token.Text, queryToken.Text) {
+		return 0.5 + 0.5*float64(utf8.RuneCountInString(queryToken.Text))/float64(utf8.RuneCountInString(token.Text))
+	}
+
+	sharedTrigrams := 0
+
+	for trigram := range queryToken.Trigrams {
+		if _, isShared := token.Trigrams[trigram]; isShared {
+			sharedTrigrams++
+		}
+	}
+
+	// Jaccard index of both sets of trigrams.
+	return float64(sharedTrigrams) / float64(len(queryToken.Trigrams)+len(token.Trigrams)-sharedTrigrams)
+}
+
+// highlightSearchTokens returns value, HTML escaped, with the words of
+// tokens whose index is in tokenIndexes between <em> tags.
+func highlightSearchTokens(value string, tokens []searchToken, tokenIndexes map[int]bool) string {
+	var builder strings.Builder
+	offset := 0
+
+	for i, token := range tokens {
+		if !tokenIndexes[i] {
+			continue
+		}
+
+		builder.WriteString(html.EscapeString(value[offset:token.Start]))
+		builder.WriteString("<em>")
+		builder.WriteString(html.EscapeString(value[token.Start:token.End]))
+		builder.WriteString("</em>")
+		offset = token.End
+	}
+
+	builder.WriteString(html.EscapeString(value[offset:]))
+
+	return builder.String()
+}
+
+// tokenizeSearchText splits text into words made of letters and digits.
+func tokenizeSearchText(text string) []searchToken {
+	tokens := []searchToken{}
+	start := -1
+
+	for i, character := range text + " " {
+		isWordCharacter := unicode.IsLetter(character) || unicode.IsDigit(character)
+
+		if isWordCharacter && start == -1 {
+			start = i
+		} else if !isWordCharacter && start != -1 {
+			folded := foldSearchText(text[start:i])
+			tokens = append(tokens, searchToken{Text: folded, Start: start, End: i, Trigrams: getTrigrams(folded)})
+			start = -1
+		}
+	}
+
+	return tokens
+}
+
+// foldSearchText lowercases text and removes its accents, so "Ángela" is
+// found searching "angela".
+func foldSearchText(text string) string {
+	// Transformers keep state, so a new one is needed every time.
+	folder := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(folder, strings.ToLower(text))
+
+	if err != nil {
+		return strings.ToLower(text)
+	}
+
+	return folded
+}
+
+// getTrigrams returns every three consecutive characters of word, which is
+// padded with two spaces before and one after so the beginning of the word
+// weighs more than the end.
+func getTrigrams(word string) map[string]struct{} {
+	characters := []rune("  " + word + " ")
+	trigrams := map[string]struct{}{}
+
+	for i := 0; i+3 <= len(characters); i++ {
+		trigrams[string(characters[i:i+3])] = struct{}{}
+	}
+
+	return trigrams
+}
+
+// searchableCustomerRepository keeps a searchIndex in sync with the
+// customers of the repository it wraps, so they can be searched.
+type searchableCustomerRepository struct {
+	CustomerRepository
+	// indexMutex makes the index updates happen one at a time. Writes to
+	// the wrapped repository aren't held by it.
+	indexMutex sync.Mutex
+	index      *searchIndex
+}
+
+// newSearchableCustomerRepository wraps wrappedRepository, indexing the
+// customers it already has.
+func newSearchableCustomerRepository(ctx context.Context, wrappedRepository CustomerRepository) (*searchableCustomerRepository, error) {
+	index := newSearchIndex()
+
+	err := forEachCustomer(ctx, wrappedRepository, func(storedCustomer customer) error {
+		index.Add(storedCustomer)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &searchableCustomerRepository{CustomerRepository: wrappedRepository, index: index}, nil
+}
+
+func (repository *searchableCustomerRepository) Create(ctx context.Context, newCustomer customer) (customer, error) {
+	createdCustomer, err := repository.CustomerRepository.Create(ctx, newCustomer)
+
+	if err == nil {
+		repository.reindex(ctx, searchIndexChange{createdCustomer.ID, createdCustomer, false})
+	}
+
+	return createdCustomer, err
+}
+
+func (repository *searchableCustomerRepository) Update(ctx context.Context, id string, newCustomerInformation customer, conditions ...writeCondition) (customer, error) {
+	updatedCustomer, err := repository.CustomerRepository.Update(ctx, id, newCustomerInformation, conditions...)
+
+	if err == nil {
+		repository.reindex(ctx, searchIndexChange{id, updatedCustomer, false})
+	}
+
+	return updatedCustomer, err
+}
+
+func (repository *searchableCustomerRepository) Delete(ctx context.Context, id string, conditions ...writeCondition) error {
+	err := repository.CustomerRepository.Delete(ctx, id, conditions...)
+
+	if err == nil {
+		repository.reindex(ctx, searchIndexChange{ID: id, IsDeleted: true})
+	}
+
+	return err
+}
+
+// searchIndexChange is a write to the wrapped repository that succeeded:
+// Customer is what it returned, unless the customer with ID was deleted.
+type searchIndexChange struct {
+	ID        string
+	Customer  customer
+	IsDeleted bool
+}
+
+// reindex updates the index after changes. Concurrent writes to the same
+// customer can finish in any order, so the customers are read again from
+// the wrapped repository while holding indexMutex: whichever update runs
+// last indexes the latest state. The customers returned by the writes are
+// only used if they can't be read.
+func (repository *searchableCustomerRepository) reindex(ctx context.Context, changes ...searchIndexChange) {
+	// The changes are already stored, so they must be indexed even if the
+	// request was cancelled in the meantime.
+	ctx = context.WithoutCancel(ctx)
+
+	repository.indexMutex.Lock()
+	defer repository.indexMutex.Unlock()
+
+	for _, change := range changes {
+		storedCustomer, err := repository.CustomerRepository.Get(ctx, change.ID)
+
+		switch {
+		case err == nil:
+			repository.index.Add(storedCustomer)
+		case errors.Is(err, ErrNotFound):
+			repository.index.Remove(change.ID)
+		case change.IsDeleted:
+			repository.index.Remove(change.ID)
+		default:
+			repository.index.Add(change.Customer)
+		}
+	}
+}
+
+func (repository *searchableCustomerRepository) Search(ctx context.Context, query searchQuery) ([]searchResult, int, error) {
+	results, total := repository.index.Search(query)
+
+	return results, total, nil
+}
+
+// WithinTransaction calls fn within a transaction of the wrapped
+// repository, and updates the index once the changes are committed.
+func (repository *searchableCustomerRepository) WithinTransaction(ctx context.Context, fn func(transaction CustomerRepository) error) error {
+	transactor, isTransactor := repository.CustomerRepository.(customerTransactor)
+
+	if !isTransactor {
+		return errors.New("the repository doesn't support transactions")
+	}
+
+	var recorder *searchIndexRecorder
+
+	err := transactor.WithinTransaction(ctx, func(transaction CustomerRepository) error {
+		recorder = &searchIndexRecorder{CustomerRepository: transaction}
+
+		return fn(recorder)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	repository.reindex(ctx, recorder.changes...)
+
+	return nil
+}
+
+// searchIndexRecorder keeps the changes made within a transaction, so they
+// only reach the index if the transaction is committed.
+type searchIndexRecorder struct {
+	CustomerRepository
+	changes []searchIndexChange
+}
+
+func (recorder *searchIndexRecorder) Create(ctx context.Context, newCustomer customer) (customer, error) {
+	createdCustomer, err := recorder.CustomerRepository.Create(ctx, newCustomer)
+
+	if err == nil {
+		recorder.changes = append(recorder.changes, searchIndexChange{createdCustomer.ID, createdCustomer, false})
+	}
+
+	return createdCustomer, err
+}
+
+func (recorder *searchIndexRecorder) Update(ctx context.Context, id string, newCustomerInformation customer, conditions ...writeCondition) (customer, error) {
+	updatedCustomer, err := recorder.CustomerRepository.Update(ctx, id, newCustomerInformation, conditions...)
+
+	if err == nil {
+		recorder.changes = append(recorder.changes, searchIndexChange{id, updatedCustomer, false})
+	}
+
+	return updatedCustomer, err
+}
+
+func (recorder *searchIndexRecorder) Delete(ctx context.Context, id string, conditions ...writeCondition) error {
+	err := recorder.CustomerRepository.Delete(ctx, id, conditions...)
+
+	if err == nil {
+		recorder.changes = append(recorder.changes, searchIndexChange{ID: id, IsDeleted: true})
+	}
+
+	return err
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func getSearchResultIds(results []searchResult) []string {
+	ids := []string{}
+
+	for _, result := range results {
+		ids = append(ids, result.Customer.ID)
+	}
+
+	return ids
+}
+
+func TestSearchIndex(t *testing.T) {
+	index := newSearchIndex()
+
+	for _, mockedCustomer := range getMockedCustomersForFiltering() {
+		index.Add(mockedCustomer)
+	}
+
+	tests := []struct {
+		name        string
+		text        string
+		expectedIds []string
+	}{
+		{"exact word", "Giavedoni", []string{"1"}},
+		{"misspelled word", "Giavedony", []string{"1"}},
+		{"partial word", "giav", []string{"1"}},
+		{"accents are ignored", "angela nunez", []string{"4"}},
+		{"email", "continental", []string{"3", "5"}},
+		{"best matches first", "helen wick", []string{"3", "2"}},
+		{"nothing similar", "xyz", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			results, total := index.Search(searchQuery{Text: test.text, Limit: 10})
+
+			assert.Equal(t, test.expectedIds, getSearchResultIds(results))
+			assert.Equal(t, len(test.expectedIds), total)
+		})
+	}
+}
+
+func TestSearchIndexScoresAndHighlights(t *testing.T) {
+	index := newSearchIndex()
+	index.Add(customer{ID: "1", Name: "Augusto", Surname: "Giavedoni", Email: "augusto.giavedoni@gmail.com", Birthdate: "2000-02-20"})
+	index.Add(customer{ID: "2", Name: "<b>Ángela</b>", Surname: "Ñúñez", Email: "angela@example.com", Birthdate: "1990-01-01"})
+
+	results, _ := index.Search(searchQuery{Text: "giavedoni", Limit: 10})
+
+	assert.Equal(t, 1.0, results[0].Score)
+	assert.Equal(t, searchHighlights{
+		"surname": "<em>Giavedoni</em>",
+		"email":   "augusto.<em>giavedoni</em>@gmail.com",
+	}, results[0].Highlights)
+
+	results, _ = index.Search(searchQuery{Text: "giavedony", Limit: 10})
+
+	assert.Less(t, results[0].Score, 1.0)
+
+	// Highlights are escaped, so they can be shown as HTML.
+	results, _ = index.Search(searchQuery{Text: "Angela", Limit: 10})
+
+	assert.Equal(t, "&lt;b&gt;<em>Ángela</em>&lt;/b&gt;", results[0].Highlights["name"])
+}
+
+func TestSearchIndexLimit(t *testing.T) {
+	index := newSearchIndex()
+
+	for _, mockedCustomer := range getMockedCustomersForFiltering() {
+		index.Add(mockedCustomer)
+	}
+
+	results, total := index.Search(searchQuery{Text: "com", Limit: 2})
+
+	assert.Len(t, results, 2)
+	assert.Equal(t, 5, total)
+}
+
+func TestSearchableRepositoryKeepsIndexInSync(t *testing.T) {
+	for name, wrappedRepository := range getRepositoriesForTesting(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			_, err := wrappedRepository.Create(ctx, getMockedCustomersForFiltering()[0])
+			assert.NoError(t, err)
+
+			// Customers stored before wrapping the repository are indexed.
+			searchableRepository, err := newSearchableCustomerRepository(ctx, wrappedRepository)
+			assert.NoError(t, err)
+
+			results, _, _ := searchableRepository.Search(ctx, searchQuery{Text: "augusto", Limit: 10})
+			assert.Equal(t, []string{"1"}, getSearchResultIds(results))
+
+			_, err = searchableRepository.Create(ctx, getMockedCustomersForFiltering()[1])
+			assert.NoError(t, err)
+
+			results, _, _ = searchableRepository.Search(ctx, searchQuery{Text: "wick", Limit: 10})
+			assert.Equal(t, []string{"2"}, getSearchResultIds(results))
+
+			updatedCustomer := getMockedCustomersForFiltering()[1]
+			updatedCustomer.Surname = "Constantine"
+
+			_, err = searchableRepository.Update(ctx, "2", updatedCustomer)
+			assert.NoError(t, err)
+
+			results, _, _ = searchableRepository.Search(ctx, searchQuery{Text: "wick", Limit: 10})
+			assert.Equal(t, []string{"2"}, getSearchResultIds(results), "the email still has wick")
+			assert.NotContains(t, results[0].Highlights, "surname")
+
+			results, _, _ = searchableRepository.Search(ctx, searchQuery{Text: "constantine", Limit: 10})
+			assert.Equal(t, []string{"2"}, getSearchResultIds(results))
+
+			assert.NoError(t, searchableRepository.Delete(ctx, "2"))
+
+			results, _, _ = searchableRepository.Search(ctx, searchQuery{Text: "constantine", Limit: 10})
+			assert.Empty(t, results)
+
+			// Failed changes don't reach the index.
+			_, err = searchableRepository.Update(ctx, "2", updatedCustomer)
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			results, _, _ = searchableRepository.Search(ctx, searchQuery{Text: "constantine", Limit: 10})
+			assert.Empty(t, results)
+		})
+	}
+}
+
+// blockingCustomerRepository holds its updates until release is closed.
+type blockingCustomerRepository struct {
+	CustomerRepository
+	updating chan struct{}
+	release  chan struct{}
+}
+
+func (repository *blockingCustomerRepository) Update(ctx context.Context, id string, newCustomerInformation customer, conditions ...writeCondition) (customer, error) {
+	close(repository.updating)
+	<-repository.release
+
+	return repository.CustomerRepository.Update(ctx, id, newCustomerInformation, conditions...)
+}
+
+func TestSearchableRepositoryDoesNotSerializeWrites(t *testing.T) {
+	ctx := context.Background()
+	wrappedRepository := &blockingCustomerRepository{newInMemoryCustomerRepository(), make(chan struct{}), make(chan struct{})}
+	searchableRepository, _ := newSearchableCustomerRepository(ctx, wrappedRepository)
+
+	_, err := searchableRepository.Create(ctx, getMockedCustomersForFiltering()[0])
+	assert.NoError(t, err)
+
+	updated := make(chan error)
+
+	go func() {
+		_, err := searchableRepository.Update(ctx, "1", getMockedCustomersForFiltering()[0])
+		updated <- err
+	}()
+
+	// Another customer can be added while the update is being written.
+	<-wrappedRepository.updating
+	_, err = searchableRepository.Create(ctx, getMockedCustomersForFiltering()[1])
+	assert.NoError(t, err)
+
+	close(wrappedRepository.release)
+	assert.NoError(t, <-updated)
+}
+
+func TestSearchableRepositoryIndexesLatestConcurrentUpdate(t *testing.T) {
+	for name, wrappedRepository := range getRepositoriesForTesting(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			searchableRepository, _ := newSearchableCustomerRepository(ctx, wrappedRepository)
+
+			_, err := searchableRepository.Create(ctx, getMockedCustomersForFiltering()[0])
+			assert.NoError(t, err)
+
+			var group sync.WaitGroup
+
+			for i := 0; i < 20; i++ {
+				group.Add(1)
+
+				go func(i int) {
+					defer group.Done()
+
+					updatedCustomer := getMockedCustomersForFiltering()[0]
+					updatedCustomer.Name = fmt.Sprintf("Name%d", i)
+					searchableRepository.Update(ctx, "1", updatedCustomer)
+				}(i)
+			}
+
+			group.Wait()
+
+			storedCustomer, err := searchableRepository.Get(ctx, "1")
+			assert.NoError(t, err)
+
+			results, _, _ := searchableRepository.Search(ctx, searchQuery{Text: "giavedoni", Limit: 10})
+			assert.Equal(t, storedCustomer, results[0].Customer)
+		})
+	}
+}
+
+func TestSearchCustomersAfterClearingThem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	clearCustomers(nil)
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	writer := sendRequestForTesting(router, "POST", "/customer", getMockedCustomersForFiltering()[0])
+	assert.Equal(t, 201, writer.Code)
+
+	writer = sendRequestForTesting(router, "GET", "/customers/search?q=Giavedoni", nil)
+	assert.Equal(t, 200, writer.Code)
+
+	var got searchResponse
+	assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &got))
+	assert.Equal(t, 1, got.Total)
+}
+
+func TestSearchCustomers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository, _ = newSearchableCustomerRepository(context.Background(), newInMemoryCustomerRepository())
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	for _, mockedCustomer := range getMockedCustomersForFiltering() {
+		writer := sendRequestForTesting(router, "POST", "/customer", mockedCustomer)
+		assert.Equal(t, 201, writer.Code)
+	}
+
+	writer := sendRequestForTesting(router, "GET", "/customers/search?q=Giavedony", nil)
+	assert.Equal(t, 200, writer.Code)
+
+	var got searchResponse
+	assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &got))
+
+	assert.Equal(t, 1, got.Total)
+	assert.Equal(t, getMockedCustomersForFiltering()[0], got.Data[0].Customer)
+	assert.Equal(t, "<em>Giavedoni</em>", got.Data[0].Highlights["surname"])
+}
+
+func TestSearchCustomersWithInvalidQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository, _ = newSearchableCustomerRepository(context.Background(), newInMemoryCustomerRepository())
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	writer := sendRequestForTesting(router, "GET", "/customers/search?q=%20-%20&limit=1000", nil)
+	assert.Equal(t, 400, writer.Code)
+
+	expected := newInvalidQueryProblem([]fieldError{
+		{"q", requiredFieldErrorCode, "Search must have at least one letter or digit"},
+		{"limit", invalidFieldErrorCode, "Limit must be a number between 1 and 100"},
+	})
+	expected.Instance = "/customers/search"
+
+	assert.Equal(t, getMockedProblemResponse(expected), getProblemFromResponse(t, writer))
+}
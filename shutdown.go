@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// serve runs httpServer on httpListener and grpcServer, when it isn't nil,
+// on grpcListener until ctx is done or one of them fails. Then it stops
+// both: they stop accepting connections and the requests in flight are
+// given until shutdownTimeout to finish, after which the remaining
+// connections are closed and context.DeadlineExceeded is returned.
+func serve(ctx context.Context, httpServer *http.Server, httpListener net.Listener, grpcServer *grpc.Server,
+	grpcListener net.Listener, shutdownTimeout time.Duration) error {
+	serverErrors := make(chan error, 2)
+
+	go func() {
+		serverErrors <- httpServer.Serve(httpListener)
+	}()
+
+	if grpcServer != nil {
+		go func() {
+			serverErrors <- grpcServer.Serve(grpcListener)
+		}()
+	}
+
+	var serveErr error
+
+	select {
+	case <-ctx.Done():
+		log.Printf("Shutting down, waiting up to %s for the requests in flight", shutdownTimeout)
+	case serveErr = <-serverErrors:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	shutdownErr := httpServer.Shutdown(shutdownCtx)
+
+	if shutdownErr != nil {
+		httpServer.Close()
+	}
+
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			grpcServer.Stop()
+			shutdownErr = shutdownCtx.Err()
+		}
+	}
+
+	if errors.Is(serveErr, http.ErrServerClosed) {
+		serveErr = nil
+	}
+
+	return errors.Join(serveErr, shutdownErr)
+}
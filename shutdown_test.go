@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveForTesting serves handler with serve until cancel is called, and
+// returns the address of the HTTP server and the error of serve.
+func serveForTesting(t *testing.T, handler http.HandlerFunc, shutdownTimeout time.Duration) (string, context.CancelFunc, <-chan error) {
+	httpListener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grpcListener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+
+	go func() {
+		served <- serve(ctx, &http.Server{Handler: handler}, httpListener, newGrpcServer(), grpcListener, shutdownTimeout)
+	}()
+
+	return "http://" + httpListener.Addr().String(), cancel, served
+}
+
+func TestServeDrainsRequestsInFlight(t *testing.T) {
+	started := make(chan struct{})
+
+	address, cancel, served := serveForTesting(t, func(writer http.ResponseWriter, request *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(writer, "done")
+	}, time.Second)
+
+	responses := make(chan string, 1)
+
+	go func() {
+		response, err := http.Get(address)
+
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		responses <- string(body)
+	}()
+
+	<-started
+	cancel()
+
+	assert.Equal(t, "done", <-responses)
+	assert.NoError(t, <-served)
+
+	// No more connections are accepted.
+	_, err := http.Get(address)
+	assert.Error(t, err)
+}
+
+func TestServeGivesUpAfterShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	address, cancel, served := serveForTesting(t, func(writer http.ResponseWriter, request *http.Request) {
+		close(started)
+		<-release
+	}, 50*time.Millisecond)
+
+	go http.Get(address)
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-served:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve didn't return after its shutdown timeout")
+	}
+}
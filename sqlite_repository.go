@@ -0,0 +1,549 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+func init() {
+	// SQLite's lower() only handles ASCII letters, so case insensitive
+	// filters use the same lowercasing as the in-memory repository.
+	sqlite.MustRegisterDeterministicScalarFunction("lower_unicode", 1, func(ctx *sqlite.FunctionContext, arguments []driver.Value) (driver.Value, error) {
+		switch value := arguments[0].(type) {
+		case string:
+			return strings.ToLower(value), nil
+		case []byte:
+			return strings.ToLower(string(value)), nil
+		default:
+			return value, nil
+		}
+	})
+}
+
+// sqliteMigration brings the database schema from one version to the
+// next.
+type sqliteMigration func(ctx context.Context, tx *sql.Tx) error
+
+// sqliteMigrations holds the steps that bring the database schema up to
+// date. The position of each migration is its schema version, which is
+// tracked with PRAGMA user_version, so new migrations must be appended.
+var sqliteMigrations = []sqliteMigration{
+	execMigration(`CREATE TABLE IF NOT EXISTS customers (
+		id        TEXT NOT NULL PRIMARY KEY,
+		name      TEXT NOT NULL,
+		surname   TEXT NOT NULL,
+		email     TEXT NOT NULL,
+		birthdate TEXT NOT NULL
+	)`),
+	addEmailKeyMigration,
+	execMigration("ALTER TABLE customers ADD COLUMN version INTEGER NOT NULL DEFAULT 1"),
+	// The last version of each deleted customer, so one created again with
+	// the same ID continues after it.
+	execMigration(`CREATE TABLE IF NOT EXISTS deleted_customers (
+		id      TEXT NOT NULL PRIMARY KEY,
+		version INTEGER NOT NULL
+	)`),
+	// The highest version of the deleted customers, so the ones created
+	// afterwards, with any ID, start after it. It replaces
+	// deleted_customers, which grew with every deleted ID.
+	execMigration(`CREATE TABLE deleted_version (
+		id      INTEGER NOT NULL PRIMARY KEY CHECK (id = 1),
+		version INTEGER NOT NULL
+	)`,
+		"INSERT INTO deleted_version (id, version) SELECT 1, COALESCE(MAX(version), 0) FROM deleted_customers",
+		"DROP TABLE deleted_customers"),
+}
+
+// execMigration returns a migration that runs statements in order.
+func execMigration(statements ...string) sqliteMigration {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// addEmailKeyMigration stores the normalized email of each customer, so
+// unique emails can be enforced with an index. It's filled from Go because
+// SQLite's lower() only handles ASCII letters.
+func addEmailKeyMigration(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, "ALTER TABLE customers ADD COLUMN email_key TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, email FROM customers")
+
+	if err != nil {
+		return err
+	}
+
+	emails := map[string]string{}
+
+	for rows.Next() {
+		var id, email string
+
+		if err := rows.Scan(&id, &email); err != nil {
+			rows.Close()
+			return err
+		}
+
+		emails[id] = email
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, email := range emails {
+		if _, err := tx.ExecContext(ctx, "UPDATE customers SET email_key = ? WHERE id = ?", normalizeEmail(email), id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sqliteCustomerRepository persists the customers in a SQLite database
+// file, so they survive restarts.
+type sqliteCustomerRepository struct {
+	db *sql.DB
+	// tx is the transaction every statement runs within, if the
+	// repository was given to WithinTransaction.
+	tx      *sql.Tx
+	options repositoryOptions
+}
+
+// sqliteExecutor is implemented by both *sql.DB and *sql.Tx.
+type sqliteExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// executor returns the transaction of the repository, or the database if
+// it has none.
+func (repository *sqliteCustomerRepository) executor() sqliteExecutor {
+	if repository.tx != nil {
+		return repository.tx
+	}
+
+	return repository.db
+}
+
+// inTransaction calls fn within the transaction of the repository or, if
+// it has none, within a new one that's committed if fn returns nil.
+// Transactions that aren't read-only take the write lock as they begin
+// (_txlock=immediate), so what they read can't change before they write.
+func (repository *sqliteCustomerRepository) inTransaction(ctx context.Context, options *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	if repository.tx != nil {
+		return fn(repository.tx)
+	}
+
+	tx, err := repository.db.BeginTx(ctx, options)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// newSQLiteCustomerRepository opens (creating it if needed) the database
+// file found at path and makes sure its schema is up to date.
+func newSQLiteCustomerRepository(path string, options ...repositoryOption) (*sqliteCustomerRepository, error) {
+	dsn := "file:" + path + "?" + url.Values{
+		"_pragma": {"busy_timeout(5000)", "journal_mode(WAL)"},
+		"_txlock": {"immediate"},
+	}.Encode()
+
+	db, err := sql.Open("sqlite", dsn)
+
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+
+	repository := &sqliteCustomerRepository{db: db, options: newRepositoryOptions(options)}
+
+	if err := repository.migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating %s: %w", path, err)
+	}
+
+	return repository, nil
+}
+
+func (repository *sqliteCustomerRepository) migrate(ctx context.Context) error {
+	tx, err := repository.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var version int
+
+	if err := tx.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	for ; version < len(sqliteMigrations); version++ {
+		if err := sqliteMigrations[version](ctx, tx); err != nil {
+			return fmt.Errorf("migration %d: %w", version+1, err)
+		}
+	}
+
+	// PRAGMA statements can't take bound parameters.
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		return err
+	}
+
+	// The unique index is created or dropped on every start, so emails can
+	// be made unique (or not) on an existing database.
+	if repository.options.uniqueEmails {
+		_, err = tx.ExecContext(ctx, "CREATE UNIQUE INDEX IF NOT EXISTS customers_email_key ON customers (email_key)")
+
+		if isConstraintViolation(err) {
+			return errors.New("unique emails can't be enforced: some customers share the same email")
+		}
+	} else {
+		_, err = tx.ExecContext(ctx, "DROP INDEX IF EXISTS customers_email_key")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (repository *sqliteCustomerRepository) Create(ctx context.Context, newCustomer customer) (customer, error) {
+	err := repository.executor().QueryRowContext(ctx,
+		`INSERT INTO customers (id, name, surname, email, birthdate, email_key, version)
+		VALUES (?, ?, ?, ?, ?, ?, 1 + (SELECT version FROM deleted_version))
+		RETURNING version`,
+		newCustomer.ID, newCustomer.Name, newCustomer.Surname, newCustomer.Email, newCustomer.Birthdate,
+		normalizeEmail(newCustomer.Email)).
+		Scan(&newCustomer.Version)
+
+	if err != nil {
+		return customer{}, translateConstraintViolation(err)
+	}
+
+	if err != nil {
+		return customer{}, err
+	}
+
+	return newCustomer, nil
+}
+
+func (repository *sqliteCustomerRepository) Get(ctx context.Context, id string) (customer, error) {
+	return getSQLiteCustomer(ctx, repository.executor(), id)
+}
+
+func getSQLiteCustomer(ctx context.Context, executor sqliteExecutor, id string) (customer, error) {
+	var foundCustomer customer
+
+	err := executor.QueryRowContext(ctx,
+		"SELECT "+sqliteSelectedColumns+" FROM customers WHERE id = ?", id).
+		Scan(&foundCustomer.ID, &foundCustomer.Name, &foundCustomer.Surname, &foundCustomer.Email, &foundCustomer.Birthdate, &foundCustomer.Version)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return customer{}, ErrNotFound
+	} else if err != nil {
+		return customer{}, err
+	}
+
+	return foundCustomer, nil
+}
+
+func (repository *sqliteCustomerRepository) List(ctx context.Context, query listQuery) (customerPage, error) {
+	positionValues, err := query.decodeCursor()
+
+	if err != nil {
+		return customerPage{}, err
+	}
+
+	sortKeys := normalizeSort(query.Sort)
+	conditions, arguments := buildSQLiteFilterConditions(query.Filters)
+	where := ""
+
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// Count and read the page within the same transaction, so both see
+	// the same customers.
+	page := customerPage{Customers: []customer{}}
+
+	err = repository.inTransaction(ctx, &sql.TxOptions{ReadOnly: true}, func(tx *sql.Tx) error {
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM customers"+where, arguments...).Scan(&page.Total); err != nil {
+			return err
+		}
+
+		if positionValues != nil {
+			positionCondition, positionArguments := buildSQLitePositionCondition(sortKeys, positionValues)
+			conditions = append(conditions, positionCondition)
+			arguments = append(arguments, positionArguments...)
+			where = " WHERE " + strings.Join(conditions, " AND ")
+		}
+
+		// Ask for one more customer than needed to know if there's another
+		// page.
+		rows, err := tx.QueryContext(ctx,
+			"SELECT "+sqliteSelectedColumns+" FROM customers"+where+
+				" ORDER BY "+buildSQLiteOrderBy(sortKeys)+" LIMIT ?",
+			append(arguments, query.normalizedLimit()+1)...)
+
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var foundCustomer customer
+
+			if err := rows.Scan(&foundCustomer.ID, &foundCustomer.Name, &foundCustomer.Surname, &foundCustomer.Email, &foundCustomer.Birthdate, &foundCustomer.Version); err != nil {
+				return err
+			}
+
+			page.Customers = append(page.Customers, foundCustomer)
+		}
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return customerPage{}, err
+	}
+
+	if limit := query.normalizedLimit(); len(page.Customers) > limit {
+		page.Customers = page.Customers[:limit]
+		page.Next = query.encodeCursor(page.Customers[limit-1])
+	}
+
+	return page, nil
+}
+
+func (repository *sqliteCustomerRepository) Update(ctx context.Context, id string, newCustomerInformation customer, conditions ...writeCondition) (customer, error) {
+	err := repository.inTransaction(ctx, nil, func(tx *sql.Tx) error {
+		storedCustomer, err := getSQLiteCustomer(ctx, tx, id)
+
+		if err != nil {
+			return err
+		}
+
+		if !newWriteConditions(conditions).allowsVersion(storedCustomer.Version) {
+			return ErrVersionMismatch
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"UPDATE customers SET name = ?, surname = ?, email = ?, birthdate = ?, email_key = ?, version = version + 1 WHERE id = ?",
+			newCustomerInformation.Name, newCustomerInformation.Surname, newCustomerInformation.Email, newCustomerInformation.Birthdate, normalizeEmail(newCustomerInformation.Email), id)
+
+		if err != nil {
+			return translateConstraintViolation(err)
+		}
+
+		newCustomerInformation.ID = id
+		newCustomerInformation.Version = storedCustomer.Version + 1
+
+		return nil
+	})
+
+	if err != nil {
+		return customer{}, err
+	}
+
+	return newCustomerInformation, nil
+}
+
+func (repository *sqliteCustomerRepository) Delete(ctx context.Context, id string, conditions ...writeCondition) error {
+	return repository.inTransaction(ctx, nil, func(tx *sql.Tx) error {
+		storedCustomer, err := getSQLiteCustomer(ctx, tx, id)
+
+		if err != nil {
+			return err
+		}
+
+		if !newWriteConditions(conditions).allowsVersion(storedCustomer.Version) {
+			return ErrVersionMismatch
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM customers WHERE id = ?", id); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE deleted_version SET version = MAX(version, ?)", storedCustomer.Version)
+
+		return err
+	})
+}
+
+// WithinTransaction calls fn with a repository whose statements run within
+// a single transaction, which is committed if fn returns nil.
+func (repository *sqliteCustomerRepository) WithinTransaction(ctx context.Context, fn func(transaction CustomerRepository) error) error {
+	return repository.inTransaction(ctx, nil, func(tx *sql.Tx) error {
+		return fn(&sqliteCustomerRepository{db: repository.db, tx: tx, options: repository.options})
+	})
+}
+
+// Close moves the changes in the write-ahead log into the database file
+// and releases it.
+func (repository *sqliteCustomerRepository) Close() error {
+	_, checkpointErr := repository.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+
+	return errors.Join(checkpointErr, repository.db.Close())
+}
+
+// sqliteSelectedColumns are the columns read into a customer, in the order
+// they're scanned.
+const sqliteSelectedColumns = "id, name, surname, email, birthdate, version"
+
+// sqliteCustomerColumns maps the fields customers can be filtered and
+// sorted by to their columns. Only these names are written into queries.
+var sqliteCustomerColumns = map[string]string{
+	customerIdField:        "id",
+	"name":                 "name",
+	"surname":              "surname",
+	"email":                "email",
+	customerBirthdateField: "birthdate",
+}
+
+// buildSQLiteFilterConditions translates filters into SQL conditions and
+// the arguments they need.
+func buildSQLiteFilterConditions(filters []customerFilter) ([]string, []interface{}) {
+	conditions := []string{}
+	arguments := []interface{}{}
+
+	for _, filter := range filters {
+		column := sqliteCustomerColumns[filter.Field]
+
+		if filter.Operator.isCaseInsensitive() {
+			column = "lower_unicode(" + column + ")"
+		}
+
+		switch filter.Operator {
+		case equalOperator, caseInsensitiveEqualOperator:
+			conditions = append(conditions, column+" = ?")
+		case prefixOperator, caseInsensitivePrefixOperator:
+			// substr counts characters, not bytes, and unlike LIKE doesn't
+			// give a meaning to any of them.
+			conditions = append(conditions, "substr("+column+", 1, ?) = ?")
+			arguments = append(arguments, utf8.RuneCountInString(filter.Value))
+		case caseInsensitiveContainsOperator:
+			conditions = append(conditions, "instr("+column+", ?) > 0")
+		case greaterThanOperator:
+			conditions = append(conditions, column+" > ?")
+		case greaterThanOrEqualOperator:
+			conditions = append(conditions, column+" >= ?")
+		case lessThanOperator:
+			conditions = append(conditions, column+" < ?")
+		case lessThanOrEqualOperator:
+			conditions = append(conditions, column+" <= ?")
+		}
+
+		arguments = append(arguments, filter.Value)
+	}
+
+	return conditions, arguments
+}
+
+// buildSQLitePositionCondition returns the condition that keeps the
+// customers after the position made of values, in the order of sortKeys.
+// For keys a and b it's a > ? OR (a = ? AND b > ?).
+func buildSQLitePositionCondition(sortKeys []sortKey, values []string) (string, []interface{}) {
+	alternatives := []string{}
+	arguments := []interface{}{}
+
+	for i, key := range sortKeys {
+		comparisons := []string{}
+		comparisonArguments := []interface{}{}
+
+		for j := 0; j < i; j++ {
+			comparisons = append(comparisons, sqliteCustomerColumns[sortKeys[j].Field]+" = ?")
+			comparisonArguments = append(comparisonArguments, values[j])
+		}
+
+		if key.Descending {
+			comparisons = append(comparisons, sqliteCustomerColumns[key.Field]+" < ?")
+		} else {
+			comparisons = append(comparisons, sqliteCustomerColumns[key.Field]+" > ?")
+		}
+
+		alternatives = append(alternatives, "("+strings.Join(comparisons, " AND ")+")")
+		arguments = append(arguments, append(comparisonArguments, values[i])...)
+	}
+
+	return "(" + strings.Join(alternatives, " OR ") + ")", arguments
+}
+
+func buildSQLiteOrderBy(sortKeys []sortKey) string {
+	orderBy := []string{}
+
+	for _, key := range sortKeys {
+		if key.Descending {
+			orderBy = append(orderBy, sqliteCustomerColumns[key.Field]+" DESC")
+		} else {
+			orderBy = append(orderBy, sqliteCustomerColumns[key.Field]+" ASC")
+		}
+	}
+
+	return strings.Join(orderBy, ", ")
+}
+
+// translateConstraintViolation returns the repository error matching the
+// constraint violated by err: ErrConflict for the primary key and
+// ErrEmailConflict for the unique index on emails. Other errors are
+// returned as they are.
+func translateConstraintViolation(err error) error {
+	var sqliteError *sqlite.Error
+
+	if !errors.As(err, &sqliteError) {
+		return err
+	}
+
+	switch sqliteError.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return ErrConflict
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE:
+		return ErrEmailConflict
+	default:
+		return err
+	}
+}
+
+// isConstraintViolation reports whether err was caused by a PRIMARY KEY or
+// UNIQUE constraint.
+func isConstraintViolation(err error) bool {
+	var sqliteError *sqlite.Error
+
+	if !errors.As(err, &sqliteError) {
+		return false
+	}
+
+	return sqliteError.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY ||
+		sqliteError.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
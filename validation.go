@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/mail"
+	"time"
+)
+
+// Codes of the fieldErrors returned by validateCustomer.
+const (
+	requiredFieldErrorCode   = "required"
+	invalidFieldErrorCode    = "invalid"
+	notAllowedFieldErrorCode = "not_allowed"
+	futureDateFieldErrorCode = "future_date"
+)
+
+// fieldError describes a problem with one field of a customer. Code is
+// meant for programs and Message for people.
+type fieldError struct {
+	Field   string `json:"field" xml:"field" yaml:"field"`
+	Code    string `json:"code" xml:"code" yaml:"code"`
+	Message string `json:"message" xml:"message" yaml:"message"`
+}
+
+// validateCustomer checks every field of customerInformation and returns
+// all the problems found, in the order of the fields. It returns no errors
+// if the customer is valid.
+func validateCustomer(customerInformation customer) []fieldError {
+	fieldErrors := []fieldError{}
+
+	if fieldError := validateCustomerId(customerInformation.ID); fieldError != nil {
+		fieldErrors = append(fieldErrors, *fieldError)
+	}
+
+	if customerInformation.Name == "" {
+		fieldErrors = append(fieldErrors, fieldError{"name", requiredFieldErrorCode, "Name cannot be null or empty"})
+	}
+
+	if customerInformation.Surname == "" {
+		fieldErrors = append(fieldErrors, fieldError{"surname", requiredFieldErrorCode, "Surname cannot be null or empty"})
+	}
+
+	if fieldError := validateCustomerEmail(customerInformation.Email); fieldError != nil {
+		fieldErrors = append(fieldErrors, *fieldError)
+	}
+
+	if fieldError := validateCustomerBirthdate(customerInformation.Birthdate); fieldError != nil {
+		fieldErrors = append(fieldErrors, *fieldError)
+	}
+
+	return fieldErrors
+}
+
+func validateCustomerId(id string) *fieldError {
+	if id == "" {
+		return &fieldError{"id", requiredFieldErrorCode, "ID cannot be null or empty"}
+	}
+
+	if err := customerIdPolicy.Validate(id); err != nil {
+		return &fieldError{"id", invalidFieldErrorCode, "ID is not valid"}
+	}
+
+	return nil
+}
+
+func validateCustomerEmail(email string) *fieldError {
+	if email == "" {
+		return &fieldError{"email", requiredFieldErrorCode, "Email cannot be null or empty"}
+	}
+
+	if _, err := mail.ParseAddress(email); err != nil {
+		return &fieldError{"email", invalidFieldErrorCode, "Email is not valid"}
+	}
+
+	return nil
+}
+
+func validateCustomerBirthdate(birthdate string) *fieldError {
+	if birthdate == "" {
+		return &fieldError{"birthdate", requiredFieldErrorCode, "Birthdate cannot be null or empty"}
+	}
+
+	customerBirthdate, err := time.Parse("2006-01-02", birthdate)
+
+	if err != nil {
+		return &fieldError{"birthdate", invalidFieldErrorCode, "Birthdate is not valid"}
+	} else if customerBirthdate.After(time.Now()) {
+		return &fieldError{"birthdate", futureDateFieldErrorCode, "Birthdate cannot be after today"}
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCustomerWithValidCustomer(t *testing.T) {
+	assert.Empty(t, validateCustomer(getMockedCustomer()))
+}
+
+func TestValidateCustomerWithEmptyCustomer(t *testing.T) {
+	assert.Equal(t, []fieldError{
+		{"id", requiredFieldErrorCode, "ID cannot be null or empty"},
+		{"name", requiredFieldErrorCode, "Name cannot be null or empty"},
+		{"surname", requiredFieldErrorCode, "Surname cannot be null or empty"},
+		{"email", requiredFieldErrorCode, "Email cannot be null or empty"},
+		{"birthdate", requiredFieldErrorCode, "Birthdate cannot be null or empty"},
+	}, validateCustomer(customer{}))
+}
+
+func TestValidateCustomerWithInvalidFields(t *testing.T) {
+	invalidCustomer := getMockedCustomer()
+	invalidCustomer.ID = "a b"
+	invalidCustomer.Email = "not an email"
+	invalidCustomer.Birthdate = "20/02/2000"
+
+	assert.Equal(t, []fieldError{
+		{"id", invalidFieldErrorCode, "ID is not valid"},
+		{"email", invalidFieldErrorCode, "Email is not valid"},
+		{"birthdate", invalidFieldErrorCode, "Birthdate is not valid"},
+	}, validateCustomer(invalidCustomer))
+}
+
+func TestValidateCustomerWithBirthdateAfterToday(t *testing.T) {
+	invalidCustomer := getMockedCustomer()
+	invalidCustomer.Birthdate = time.Now().AddDate(0, 1, 0).Format("2006-01-02")
+
+	assert.Equal(t, []fieldError{
+		{"birthdate", futureDateFieldErrorCode, "Birthdate cannot be after today"},
+	}, validateCustomer(invalidCustomer))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiRoute is an endpoint of a version of the API. Path doesn't have the
+// prefix of the version.
+type apiRoute struct {
+	Method  string
+	Path    string
+	Handler gin.HandlerFunc
+}
+
+// apiVersion is a version of the API, served under /Name, where Name is v
+// followed by its number. Each version has the routes of the previous one,
+// replaced or added to by its Routes, so a version that changes the
+// representation of customers only needs the handlers that read or write
+// it.
+type apiVersion struct {
+	Name   string
+	Routes []apiRoute
+}
+
+// apiVersions are the versions of the API, from the oldest to the newest.
+var apiVersions = []apiVersion{
+	{
+		Name: "v1",
+		Routes: []apiRoute{
+			{http.MethodPost, "/customer", postCustomer},
+			{http.MethodGet, "/customers", getCustomers},
+			{http.MethodGet, "/customers/search", searchCustomers},
+			{http.MethodPost, "/customers/bulk", postCustomers},
+			{http.MethodPut, "/customers/bulk", updateCustomers},
+			{http.MethodDelete, "/customers/bulk", deleteCustomers},
+			{http.MethodPost, "/customers/import", importCustomers},
+			{http.MethodGet, "/customers/export", exportCustomers},
+			{http.MethodGet, "/customer/:id", getCustomerById},
+			{http.MethodPut, "/customer/:id", updateCustomer},
+			{http.MethodPatch, "/customer/:id", patchCustomer},
+			{http.MethodDelete, "/customer/:id", deleteCustomer},
+		},
+	},
+}
+
+// legacyApiVersion is the version still served without a prefix, as every
+// route was before the API had versions. Those routes are deprecated and
+// will be removed at legacySunset.
+const legacyApiVersion = "v1"
+
+var (
+	legacyDeprecation = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	legacySunset      = time.Date(2027, time.April, 18, 0, 0, 0, 0, time.UTC)
+)
+
+// registerApiVersions registers the routes of every version of apiVersions
+// under its prefix, along with the deprecated routes without one.
+func registerApiVersions(router *gin.Engine) {
+	routes := []apiRoute{}
+
+	for _, version := range apiVersions {
+		routes = mergeApiRoutes(routes, version.Routes)
+		registerApiRoutes(router.Group("/"+version.Name), routes)
+
+		if version.Name == legacyApiVersion {
+			registerApiRoutes(router.Group("", deprecationMiddleware()), routes)
+		}
+	}
+}
+
+// mergeApiRoutes returns the routes of the previous version with the ones
+// of the next version, which replace those with the same method and path.
+func mergeApiRoutes(previousRoutes []apiRoute, nextRoutes []apiRoute) []apiRoute {
+	routes := []apiRoute{}
+
+	for _, previousRoute := range previousRoutes {
+		isReplaced := false
+
+		for _, nextRoute := range nextRoutes {
+			if nextRoute.Method == previousRoute.Method && nextRoute.Path == previousRoute.Path {
+				isReplaced = true
+			}
+		}
+
+		if !isReplaced {
+			routes = append(routes, previousRoute)
+		}
+	}
+
+	return append(routes, nextRoutes...)
+}
+
+func registerApiRoutes(group *gin.RouterGroup, routes []apiRoute) {
+	for _, route := range routes {
+		group.Handle(route.Method, route.Path, route.Handler)
+	}
+}
+
+// apiVersionPrefix matches the prefix of the versions, which are named v
+// followed by their number.
+var apiVersionPrefix = regexp.MustCompile(`^/v[0-9]+/`)
+
+// splitApiVersion splits the path of a route into the prefix of its
+// version, which is empty for the deprecated routes and the ones that
+// aren't versioned, and the rest of it.
+func splitApiVersion(path string) (string, string) {
+	prefix := strings.TrimSuffix(apiVersionPrefix.FindString(path), "/")
+
+	return prefix, strings.TrimPrefix(path, prefix)
+}
+
+// deprecationMiddleware tells the clients of the routes without a version
+// that they're deprecated (RFC 9745) and when they'll stop working
+// (RFC 8594).
+func deprecationMiddleware() gin.HandlerFunc {
+	deprecation := fmt.Sprintf("@%d", legacyDeprecation.Unix())
+	sunset := legacySunset.Format(http.TimeFormat)
+
+	return func(context *gin.Context) {
+		context.Header("Deprecation", deprecation)
+		context.Header("Sunset", sunset)
+		context.Next()
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func getMockedCustomerJSONForTesting(t *testing.T) string {
+	jsonbytes, err := json.Marshal(getMockedCustomerResponse())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(jsonbytes)
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+	router := setupRouter()
+
+	defer clearCustomers(nil)
+
+	writer := sendRequestForTesting(router, "POST", "/v1/customer", getMockedCustomer())
+	assert.Equal(t, 201, writer.Code)
+	assert.Equal(t, "/v1/customer/1", writer.Header().Get("Location"))
+	assert.Empty(t, writer.Header().Get("Deprecation"))
+
+	writer = sendRequestForTesting(router, "GET", "/v1/customer/1", nil)
+	assert.Equal(t, 200, writer.Code)
+	assert.JSONEq(t, getMockedCustomerJSONForTesting(t), writer.Body.String())
+
+	request := httptest.NewRequest("GET", "/v1/customers/export", nil)
+	writer = httptest.NewRecorder()
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, 200, writer.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", writer.Header().Get("Content-Type"))
+
+	// The routes without a version still work, but are deprecated.
+	writer = sendRequestForTesting(router, "GET", "/customer/1", nil)
+	assert.Equal(t, 200, writer.Code)
+	assert.Equal(t, fmt.Sprintf("@%d", legacyDeprecation.Unix()), writer.Header().Get("Deprecation"))
+	assert.Equal(t, legacySunset.Format(http.TimeFormat), writer.Header().Get("Sunset"))
+
+	writer = sendRequestForTesting(router, "GET", "/openapi.json", nil)
+	assert.Empty(t, writer.Header().Get("Deprecation"))
+
+	writer = sendRequestForTesting(router, "GET", "/v1/graphql", nil)
+	assert.Equal(t, 404, writer.Code)
+}
+
+func TestNewApiVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository = newInMemoryCustomerRepository()
+
+	previousVersions := apiVersions
+
+	defer func() {
+		apiVersions = previousVersions
+		clearCustomers(nil)
+	}()
+
+	// A version that only changes how a customer is returned.
+	apiVersions = append(apiVersions, apiVersion{
+		Name: "v2",
+		Routes: []apiRoute{{http.MethodGet, "/customer/:id", func(context *gin.Context) {
+			storedCustomer, err := repository.Get(context.Request.Context(), context.Param("id"))
+
+			if err != nil {
+				respondWithRepositoryError(err, context)
+				return
+			}
+
+			context.JSON(http.StatusOK, gin.H{"id": storedCustomer.ID, "fullName": storedCustomer.Name + " " + storedCustomer.Surname})
+		}}},
+	})
+
+	router := setupRouter()
+
+	// The rest of the routes are the ones of v1.
+	writer := sendRequestForTesting(router, "POST", "/v2/customer", getMockedCustomer())
+	assert.Equal(t, 201, writer.Code)
+	assert.Equal(t, "/v2/customer/1", writer.Header().Get("Location"))
+
+	writer = sendRequestForTesting(router, "GET", "/v2/customer/1", nil)
+	assert.Equal(t, 200, writer.Code)
+	assert.JSONEq(t, `{"id": "1", "fullName": "Augusto Giavedoni"}`, writer.Body.String())
+
+	writer = sendRequestForTesting(router, "GET", "/v1/customer/1", nil)
+	assert.JSONEq(t, getMockedCustomerJSONForTesting(t), writer.Body.String())
+
+	// The routes without a version stay the ones of v1.
+	writer = sendRequestForTesting(router, "GET", "/customer/1", nil)
+	assert.JSONEq(t, getMockedCustomerJSONForTesting(t), writer.Body.String())
+}